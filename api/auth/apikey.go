@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	ScopePostsWrite = "posts:write"
+	ScopeUsersWrite = "users:write"
+)
+
+// APIKeyPrefix starts every personal API key so they are easy to recognise, e.g by secret scanners
+const APIKeyPrefix = "mdm_"
+
+var scopes = []string{ScopePostsWrite, ScopeUsersWrite}
+
+func ValidScope(scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyIdentity is who an API key acts for and what it is allowed to do
+type APIKeyIdentity struct {
+	KeyID  uint64
+	UserID uint32
+	Role   string
+	Scopes []string
+}
+
+func (k *APIKeyIdentity) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyStore looks up API keys, rejecting unknown, revoked and expired keys
+type APIKeyStore interface {
+	AuthenticateAPIKey(key string) (*APIKeyIdentity, error)
+}
+
+var apiKeys APIKeyStore
+
+// SetAPIKeyStore enables `Authorization: ApiKey ...` authentication
+func SetAPIKeyStore(store APIKeyStore) {
+	apiKeys = store
+}
+
+// GenerateAPIKey returns a new API key and the prefix that is kept in clear to tell keys apart
+func GenerateAPIKey() (string, string, error) {
+	secret, err := GenerateOpaqueToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	key := APIKeyPrefix + secret
+	return key, key[:len(APIKeyPrefix)+8], nil
+}
+
+// ExtractAPIKey returns the API key sent with the `ApiKey` authorization scheme
+func ExtractAPIKey(r *http.Request) string {
+	parts := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(parts) == 2 && strings.EqualFold(parts[0], "ApiKey") {
+		return parts[1]
+	}
+	return ""
+}
+
+// ExtractAPIKeyIdentity authenticates the API key on the request
+func ExtractAPIKeyIdentity(r *http.Request) (*APIKeyIdentity, error) {
+	key := ExtractAPIKey(r)
+	if key == "" {
+		return nil, errors.New("No API key")
+	}
+	if apiKeys == nil {
+		return nil, errors.New("API keys are not enabled")
+	}
+	return apiKeys.AuthenticateAPIKey(key)
+}
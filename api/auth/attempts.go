@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AttemptTracker counts failed attempts per key (an account or an IP address) and
+// says how long the key has to wait before it may try again
+type AttemptTracker interface {
+	// Wait returns how long the key has to wait before its next attempt, zero when it may try now
+	Wait(key string, now time.Time) time.Duration
+	Failure(key string, now time.Time)
+	// Reserve is Wait and Failure in one step: unless the key has to wait, the attempt counts as failed before it is
+	// made, so that attempts made at the same time cannot all get in before any of them failed
+	Reserve(key string, now time.Time) time.Duration
+	// Release takes back the failure Reserve counted, for an attempt that did not fail
+	Release(key string)
+	Reset(key string)
+}
+
+// ThrottlePolicy describes how quickly failed attempts slow a key down
+type ThrottlePolicy struct {
+	FreeAttempts int           // Failures allowed before any delay
+	BaseDelay    time.Duration // Delay after the first failure past FreeAttempts, doubled for every further failure
+	MaxDelay     time.Duration
+	MaxAttempts  int           // Failures after which the key is locked out
+	Lockout      time.Duration // How long a lockout lasts
+	Window       time.Duration // Failures older than this are forgotten
+}
+
+func DefaultThrottlePolicy() ThrottlePolicy {
+	return ThrottlePolicy{
+		FreeAttempts: 3,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		MaxAttempts:  10,
+		Lockout:      time.Minute * 15,
+		Window:       time.Minute * 15,
+	}
+}
+
+// WithEnv overrides the lockout threshold and duration from the environment, e.g
+// LOGIN_MAX_ATTEMPTS=10 and LOGIN_LOCKOUT=15m, keeping the policy's values for anything not set
+func (policy ThrottlePolicy) WithEnv(maxAttemptsVar string) ThrottlePolicy {
+	if n, err := strconv.Atoi(os.Getenv(maxAttemptsVar)); err == nil && n > 0 {
+		policy.MaxAttempts = n
+		if policy.FreeAttempts >= n {
+			policy.FreeAttempts = n - 1
+		}
+	}
+	if d, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT")); err == nil && d > 0 {
+		policy.Lockout = d
+		if d > policy.Window {
+			policy.Window = d
+		}
+	}
+	return policy
+}
+
+// delay is how long to wait after the given number of failures
+func (p ThrottlePolicy) delay(failures int) time.Duration {
+	if failures >= p.MaxAttempts {
+		return p.Lockout
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+	d := time.Duration(float64(p.BaseDelay) * math.Pow(2, float64(failures-p.FreeAttempts-1)))
+	if d > p.MaxDelay || d <= 0 {
+		return p.MaxDelay
+	}
+	return d
+}
+
+type attempts struct {
+	failures    int
+	lastFailure time.Time
+}
+
+// MemoryAttemptTracker keeps attempts in memory, which is enough for a single instance and for tests
+type MemoryAttemptTracker struct {
+	mu       sync.Mutex
+	policy   ThrottlePolicy
+	attempts map[string]*attempts
+	failures int
+}
+
+func NewMemoryAttemptTracker(policy ThrottlePolicy) *MemoryAttemptTracker {
+	return &MemoryAttemptTracker{policy: policy, attempts: map[string]*attempts{}}
+}
+
+func (t *MemoryAttemptTracker) Wait(key string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.wait(key, now)
+}
+
+func (t *MemoryAttemptTracker) Failure(key string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.failure(key, now)
+}
+
+func (t *MemoryAttemptTracker) Reserve(key string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	wait := t.wait(key, now)
+	if wait == 0 {
+		t.failure(key, now)
+	}
+	return wait
+}
+
+func (t *MemoryAttemptTracker) Release(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	a, ok := t.attempts[key]
+	if !ok {
+		return
+	}
+	a.failures--
+	if a.failures <= 0 {
+		delete(t.attempts, key)
+	}
+}
+
+func (t *MemoryAttemptTracker) wait(key string, now time.Time) time.Duration {
+	a, ok := t.attempts[key]
+	if !ok {
+		return 0
+	}
+	if now.Sub(a.lastFailure) > t.policy.Window {
+		delete(t.attempts, key)
+		return 0
+	}
+	wait := a.lastFailure.Add(t.policy.delay(a.failures)).Sub(now)
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+func (t *MemoryAttemptTracker) failure(key string, now time.Time) {
+	a, ok := t.attempts[key]
+	if !ok || now.Sub(a.lastFailure) > t.policy.Window {
+		a = &attempts{}
+		t.attempts[key] = a
+	}
+	a.failures++
+	a.lastFailure = now
+
+	// Forget stale keys now and then so spraying many keys can't grow the map forever
+	t.failures++
+	if t.failures%1000 == 0 {
+		for k, a := range t.attempts {
+			if now.Sub(a.lastFailure) > t.policy.Window {
+				delete(t.attempts, k)
+			}
+		}
+	}
+}
+
+func (t *MemoryAttemptTracker) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.attempts, key)
+}
@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Key is a key used to sign or verify access tokens. Verification only keys have no Private key
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.PrivateKey
+	Public  crypto.PublicKey
+}
+
+// KeySet holds the key new tokens are signed with and every key tokens are still accepted from.
+// Keeping the previous keys around in Verification is how keys are rotated without logging everyone out
+type KeySet struct {
+	Signing      *Key
+	Verification map[string]*Key
+}
+
+var keys *KeySet
+
+// SetKeySet switches token signing to the key set, a nil key set goes back to HMAC with API_SECRET
+func SetKeySet(ks *KeySet) {
+	keys = ks
+}
+
+// NewKeySet builds a key set signing with the given key and also accepting tokens signed by the others
+func NewKeySet(signing *Key, others ...*Key) (*KeySet, error) {
+	if signing == nil || signing.Private == nil {
+		return nil, errors.New("A private signing key is required")
+	}
+	ks := &KeySet{Signing: signing, Verification: map[string]*Key{}}
+	for _, key := range append([]*Key{signing}, others...) {
+		ks.Verification[key.ID] = key
+	}
+	return ks, nil
+}
+
+// LoadKeysFromEnv configures token signing from the environment:
+// JWT_SIGNING_METHOD is HS256 (the default, using API_SECRET), RS256 or EdDSA,
+// JWT_SIGNING_KEY_FILE is the PEM private key new tokens are signed with and
+// JWT_VERIFICATION_KEY_FILES is a comma separated list of PEM keys that are still accepted
+func LoadKeysFromEnv() error {
+	method := os.Getenv("JWT_SIGNING_METHOD")
+	if method == "" || method == jwt.SigningMethodHS256.Alg() {
+		SetKeySet(nil)
+		return nil
+	}
+	signing, err := LoadKeyFile(os.Getenv("JWT_SIGNING_KEY_FILE"))
+	if err != nil {
+		return err
+	}
+	if signing.Method.Alg() != method {
+		return fmt.Errorf("JWT_SIGNING_KEY_FILE holds a %s key, expected %s", signing.Method.Alg(), method)
+	}
+	others := []*Key{}
+	for _, path := range strings.Split(os.Getenv("JWT_VERIFICATION_KEY_FILES"), ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		key, err := LoadKeyFile(path)
+		if err != nil {
+			return err
+		}
+		key.Private = nil
+		others = append(others, key)
+	}
+	ks, err := NewKeySet(signing, others...)
+	if err != nil {
+		return err
+	}
+	SetKeySet(ks)
+	return nil
+}
+
+// LoadKeyFile reads a RSA or Ed25519 key, private or public, from a PEM file
+func LoadKeyFile(path string) (*Key, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeyPEM(data)
+}
+
+func ParseKeyPEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("No PEM data found")
+	}
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("Unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(parsed)
+}
+
+// NewKey wraps a RSA or Ed25519 key, its id is the RFC 7638 thumbprint of the public key
+func NewKey(k interface{}) (*Key, error) {
+	key := &Key{}
+	switch k := k.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.Private, key.Public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.Public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.Private, key.Public = SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.Public = SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("Unsupported key type %T", k)
+	}
+	thumbprint, err := key.thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	key.ID = thumbprint
+	return key, nil
+}
+
+// JWK is a public key in the JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k *Key) JWK() JWK {
+	jwk := JWK{Use: "sig", Alg: k.Method.Alg(), Kid: k.ID}
+	switch public := k.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
+
+func (k *Key) thumbprint() (string, error) {
+	jwk := k.JWK()
+	// The members have to be in lexicographic order, which is what marshalling a map gives us
+	var members map[string]string
+	switch jwk.Kty {
+	case "RSA":
+		members = map[string]string{"e": jwk.E, "kty": jwk.Kty, "n": jwk.N}
+	case "OKP":
+		members = map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X}
+	}
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// PublicJWKS returns every key tokens are verified with, HMAC secrets are never published
+func PublicJWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	if keys == nil {
+		return set
+	}
+	for _, key := range keys.Verification {
+		set.Keys = append(set.Keys, key.JWK())
+	}
+	return set
+}
+
+func signToken(claims jwt.MapClaims) (string, error) {
+	if keys == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(os.Getenv("API_SECRET")))
+	}
+	token := jwt.NewWithClaims(keys.Signing.Method, claims)
+	token.Header["kid"] = keys.Signing.ID
+	return token.SignedString(keys.Signing.Private)
+}
+
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	if keys == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(os.Getenv("API_SECRET")), nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := keys.Verification[kid]
+	if !ok {
+		return nil, fmt.Errorf("Unknown signing key: %v", token.Header["kid"])
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.Public, nil
+}
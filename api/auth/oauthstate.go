@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	OAuthStateLifetime   = time.Minute * 10 // How long a user has to finish signing in with an identity provider
+	OAuthStateCookieName = "oauth_state"
+)
+
+// OAuthState is what the callback of the authorization code flow needs to remember from its start
+type OAuthState struct {
+	Provider string
+	State    string
+	Nonce    string
+	Verifier string // PKCE code verifier
+	Session  bool   // Finish with a cookie session instead of tokens in the body
+}
+
+// CreateOAuthStateToken signs the state so it can be kept in a cookie on the user's browser
+func CreateOAuthStateToken(state OAuthState) (string, error) {
+	claims := jwt.MapClaims{}
+	claims["authorized"] = false
+	claims["oauth_provider"] = state.Provider
+	claims["oauth_state"] = state.State
+	claims["nonce"] = state.Nonce
+	claims["code_verifier"] = state.Verifier
+	claims["session"] = state.Session
+	claims["exp"] = time.Now().Add(OAuthStateLifetime).Unix()
+	return signToken(claims)
+}
+
+func ParseOAuthStateToken(tokenString string) (*OAuthState, error) {
+	token, err := jwt.Parse(tokenString, verificationKey)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("Invalid state")
+	}
+	state := &OAuthState{}
+	state.Provider, _ = claims["oauth_provider"].(string)
+	state.State, _ = claims["oauth_state"].(string)
+	state.Nonce, _ = claims["nonce"].(string)
+	state.Verifier, _ = claims["code_verifier"].(string)
+	state.Session, _ = claims["session"].(bool)
+	if state.Provider == "" || state.State == "" || state.Nonce == "" || state.Verifier == "" {
+		return nil, errors.New("Token is not a state token")
+	}
+	return state, nil
+}
+
+// SetOAuthStateCookie keeps the signed state on the browser that started the flow, which is what stops a
+// callback crafted by someone else from logging the user into the wrong account
+func SetOAuthStateCookie(w http.ResponseWriter, token string) {
+	cookie := sessionCookie(OAuthStateCookieName, token, OAuthStateLifetime, true)
+	// The callback is a cross-site navigation, a strict cookie would not be sent along
+	cookie.SameSite = http.SameSiteLaxMode
+	http.SetCookie(w, cookie)
+}
+
+func ExtractOAuthStateCookie(r *http.Request) string {
+	cookie, err := r.Cookie(OAuthStateCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+func ClearOAuthStateCookie(w http.ResponseWriter) {
+	cookie := sessionCookie(OAuthStateCookieName, "", 0, true)
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID     uint32
+	Role       string
+	TokenID    string // The jti of an access token, or the id of an API key
+	AuthMethod string
+	Scopes     []string  // Only set for API keys, access tokens are not limited by scopes
+	ExpiresAt  time.Time // Zero for API keys without an expiry
+	Cookie     bool      // Authenticated by the session cookie, so state changing requests need a CSRF token
+}
+
+type contextKey int
+
+const principalKey contextKey = 0
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// PrincipalFromContext returns the principal stored by SetMiddlewareAuthentication
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey).(*Principal)
+	return p, ok && p != nil
+}
+
+// PrincipalFromRequest returns the principal of the request. Handlers behind SetMiddlewareAuthentication get the
+// one parsed by the middleware, handlers called without it (e.g in tests) authenticate the request themselves
+func PrincipalFromRequest(r *http.Request) (*Principal, error) {
+	if p, ok := PrincipalFromContext(r.Context()); ok {
+		return p, nil
+	}
+	return Authenticate(r)
+}
+
+// AccountStore tells whether the account a token was issued to may still use it
+type AccountStore interface {
+	AccountActive(uid uint32) (bool, error)
+}
+
+var accounts AccountStore
+
+// SetAccountStore makes access tokens stop working as soon as their account is deleted, rather than when they expire
+func SetAccountStore(store AccountStore) {
+	accounts = store
+}
+
+// Authenticate parses the access token or API key on the request, rejecting revoked tokens
+func Authenticate(r *http.Request) (*Principal, error) {
+	if ExtractAPIKey(r) != "" {
+		identity, err := ExtractAPIKeyIdentity(r)
+		if err != nil {
+			return nil, err
+		}
+		return &Principal{
+			UserID:     identity.UserID,
+			Role:       identity.Role,
+			TokenID:    fmt.Sprintf("apikey:%d", identity.KeyID),
+			AuthMethod: AuthMethodAPIKey,
+			Scopes:     identity.Scopes,
+		}, nil
+	}
+
+	tokenString, cookie := extractToken(r)
+	token, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("Invalid token")
+	}
+	uid, err := strconv.ParseUint(fmt.Sprintf("%.0f", claims["userId"]), 10, 32)
+	if err != nil || uid == 0 {
+		return nil, errors.New("Token has no user")
+	}
+	jti, ok := claims["jti"].(string)
+	if !ok || jti == "" {
+		return nil, errors.New("Token has no id")
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("Token has no expiry")
+	}
+	role, ok := claims["role"].(string)
+	if !ok || !ValidRole(role) {
+		return nil, errors.New("Token has no role")
+	}
+	revoked, err := revocations.IsRevoked(jti)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errors.New("Token has been revoked")
+	}
+	if accounts != nil {
+		active, err := accounts.AccountActive(uint32(uid))
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return nil, errors.New("Account has been deleted")
+		}
+	}
+	return &Principal{
+		UserID:     uint32(uid),
+		Role:       role,
+		TokenID:    jti,
+		AuthMethod: AuthMethodJWT,
+		ExpiresAt:  time.Unix(int64(exp), 0),
+		Cookie:     cookie,
+	}, nil
+}
+
+func (p *Principal) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if p.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) HasPermission(permission Permission) bool {
+	return HasPermission(p.Role, permission)
+}
+
+// HasScope reports whether an API key covers the scope, access tokens always do
+func (p *Principal) HasScope(scope string) bool {
+	if p.AuthMethod != AuthMethodAPIKey {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CanActOn reports whether the principal owns the resource or has been granted the permission to act on anyone's
+func (p *Principal) CanActOn(ownerID uint32, anyPermission Permission) bool {
+	if p.UserID == ownerID {
+		return true
+	}
+	return p.HasPermission(anyPermission)
+}
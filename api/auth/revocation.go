@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// RevocationStore keeps the ids (jti) of access tokens that were revoked before they expired
+type RevocationStore interface {
+	Revoke(jti string, expiresAt time.Time) error
+	IsRevoked(jti string) (bool, error)
+	// Purge removes entries whose token has expired anyway, returning how many were removed
+	Purge(now time.Time) (int64, error)
+}
+
+var revocations RevocationStore = NewMemoryRevocationStore()
+
+// SetRevocationStore replaces the store used to check and record revoked tokens
+func SetRevocationStore(store RevocationStore) {
+	revocations = store
+}
+
+// RevokeToken revokes the principal's access token until it expires
+func RevokeToken(p *Principal) error {
+	if p.AuthMethod != AuthMethodJWT {
+		return errors.New("Only access tokens can be revoked")
+	}
+	return revocations.Revoke(p.TokenID, p.ExpiresAt)
+}
+
+// StartRevocationGC purges expired entries from the store every interval until the returned stop func is called
+func StartRevocationGC(store RevocationStore, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				purged, err := store.Purge(now)
+				if err != nil {
+					log.Printf("Revocation list purge failed: %v", err)
+					continue
+				}
+				if purged > 0 {
+					log.Printf("Purged %d expired entries from the revocation list", purged)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// MemoryRevocationStore is a RevocationStore for a single instance and for tests
+type MemoryRevocationStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{revoked: map[string]time.Time{}}
+}
+
+func (s *MemoryRevocationStore) Revoke(jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return errors.New("Token id Required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsRevoked(jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.revoked[jti]
+	return ok, nil
+}
+
+func (s *MemoryRevocationStore) Purge(now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var purged int64
+	for jti, exp := range s.revoked {
+		if exp.Before(now) {
+			delete(s.revoked, jti)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,46 @@
+package auth
+
+const (
+	RoleReader = "reader"
+	RoleAuthor = "author"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// DefaultRole is given to every new account
+const DefaultRole = RoleAuthor
+
+type Permission string
+
+const (
+	PermPostsCreate    Permission = "posts:create"
+	PermPostsUpdateAny Permission = "posts:update:any"
+	PermPostsDeleteAny Permission = "posts:delete:any"
+	PermUsersUpdateAny Permission = "users:update:any"
+	PermUsersDeleteAny Permission = "users:delete:any"
+	PermUsersManage    Permission = "users:manage"
+	PermTagsManage     Permission = "tags:manage"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleReader: {},
+	RoleAuthor: {PermPostsCreate},
+	RoleEditor: {PermPostsCreate, PermPostsUpdateAny, PermPostsDeleteAny},
+	RoleAdmin: {PermPostsCreate, PermPostsUpdateAny, PermPostsDeleteAny,
+		PermUsersUpdateAny, PermUsersDeleteAny, PermUsersManage, PermTagsManage},
+}
+
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether the role grants the permission
+func HasPermission(role string, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	SessionCookieName = "access_token"
+	RefreshCookieName = "refresh_token"
+	CSRFCookieName    = "csrf_token"
+	CSRFHeaderName    = "X-CSRF-Token"
+)
+
+// SessionConfig controls where access tokens may be read from
+type SessionConfig struct {
+	Cookies     bool // Browsers may ask Login for an HttpOnly cookie session instead of tokens in the body
+	QueryTokens bool // Access tokens are accepted in the ?token= query string, where they end up in logs
+	Secure      bool
+	SameSite    http.SameSite
+	Domain      string
+}
+
+func DefaultSessionConfig() SessionConfig {
+	return SessionConfig{QueryTokens: true, Secure: true, SameSite: http.SameSiteLaxMode}
+}
+
+var sessions = DefaultSessionConfig()
+
+func SetSessionConfig(config SessionConfig) {
+	sessions = config
+}
+
+func CookieSessionsEnabled() bool {
+	return sessions.Cookies
+}
+
+// SessionConfigFromEnv reads SESSION_COOKIES, SESSION_COOKIE_SECURE, SESSION_COOKIE_SAMESITE (lax, strict or none),
+// SESSION_COOKIE_DOMAIN and ALLOW_QUERY_TOKEN on top of the defaults
+func SessionConfigFromEnv() SessionConfig {
+	config := DefaultSessionConfig()
+	config.Cookies = os.Getenv("SESSION_COOKIES") == "true"
+	config.QueryTokens = os.Getenv("ALLOW_QUERY_TOKEN") != "false"
+	config.Secure = os.Getenv("SESSION_COOKIE_SECURE") != "false"
+	config.Domain = os.Getenv("SESSION_COOKIE_DOMAIN")
+	switch strings.ToLower(os.Getenv("SESSION_COOKIE_SAMESITE")) {
+	case "strict":
+		config.SameSite = http.SameSiteStrictMode
+	case "none":
+		config.SameSite = http.SameSiteNoneMode
+	}
+	return config
+}
+
+func sessionCookie(name, value string, maxAge time.Duration, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   sessions.Domain,
+		MaxAge:   int(maxAge.Seconds()),
+		Secure:   sessions.Secure,
+		HttpOnly: httpOnly,
+		SameSite: sessions.SameSite,
+	}
+}
+
+// SetSessionCookies moves the token pair into HttpOnly cookies and sets a fresh CSRF token the frontend can read.
+// The returned details no longer carry the tokens themselves
+func SetSessionCookies(w http.ResponseWriter, tokens *TokenDetails) (*TokenDetails, error) {
+	csrfToken, err := GenerateOpaqueToken(32)
+	if err != nil {
+		return nil, err
+	}
+	http.SetCookie(w, sessionCookie(SessionCookieName, tokens.AccessToken, AccessTokenLifetime, true))
+	http.SetCookie(w, sessionCookie(RefreshCookieName, tokens.RefreshToken, RefreshTokenLifetime, true))
+	http.SetCookie(w, sessionCookie(CSRFCookieName, csrfToken, RefreshTokenLifetime, false))
+	return &TokenDetails{
+		TokenType: "Cookie",
+		ExpiresIn: tokens.ExpiresIn,
+		CSRFToken: csrfToken,
+	}, nil
+}
+
+func ClearSessionCookies(w http.ResponseWriter) {
+	for _, name := range []string{SessionCookieName, RefreshCookieName, CSRFCookieName} {
+		cookie := sessionCookie(name, "", 0, name != CSRFCookieName)
+		cookie.MaxAge = -1
+		http.SetCookie(w, cookie)
+	}
+}
+
+// ExtractSessionCookie returns the value of one of the session cookies, if cookie sessions are enabled
+func ExtractSessionCookie(r *http.Request, name string) string {
+	if !sessions.Cookies {
+		return ""
+	}
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// ValidCSRF checks the double submitted CSRF token, the header has to repeat the value of the cookie.
+// Safe methods do not change state and are always let through
+func ValidCSRF(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	cookie, err := r.Cookie(CSRFCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	header := r.Header.Get(CSRFHeaderName)
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(header)) == 1
+}
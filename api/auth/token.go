@@ -2,75 +2,95 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"log"
 	"net/http"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 )
 
-func CreateToken(userId uint32) (string, error) {
+const (
+	AccessTokenLifetime  = time.Hour * 1       // Access tokens expire after 1 hour
+	RefreshTokenLifetime = time.Hour * 24 * 30 // Refresh tokens expire after 30 days
+)
+
+// TokenDetails is the access/refresh token pair handed out on login and refresh.
+// When a second factor is still needed only MFARequired and MFAToken are set,
+// for cookie sessions the tokens are in cookies and only the CSRF token is set
+type TokenDetails struct {
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+	CSRFToken    string `json:"csrf_token,omitempty"`
+}
+
+func CreateToken(userId uint32, role string) (string, error) {
+	jti, err := GenerateOpaqueToken(16)
+	if err != nil {
+		return "", err
+	}
 	claims := jwt.MapClaims{}
 	claims["authorized"] = true
+	claims["jti"] = jti
 	claims["userId"] = userId
-	claims["exp"] = time.Now().Add(time.Hour * 1).Unix()	// Token expires after 1 hour
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("API_SECRET")))
+	claims["role"] = role
+	claims["exp"] = time.Now().Add(AccessTokenLifetime).Unix()
+	return signToken(claims)
 }
 
 func ExtractToken(r *http.Request) string {
-	keys := r.URL.Query()
-	token := keys.Get("token")
-	if token != "" {
-		return token
+	token, _ := extractToken(r)
+	return token
+}
+
+// extractToken also reports whether the token came from the session cookie, which makes the request subject to CSRF checks
+func extractToken(r *http.Request) (string, bool) {
+	if sessions.QueryTokens {
+		keys := r.URL.Query()
+		token := keys.Get("token")
+		if token != "" {
+			return token, false
+		}
 	}
 	tokenBearer := r.Header.Get("Authorization")
-	if len(strings.Split(tokenBearer, " ")) == 2 {
-		return strings.Split(tokenBearer, " ")[1]
+	if len(strings.Split(tokenBearer, " ")) == 2 && ExtractAPIKey(r) == "" {
+		return strings.Split(tokenBearer, " ")[1], false
 	}
-	return ""
+	if token := ExtractSessionCookie(r, SessionCookieName); token != "" {
+		return token, true
+	}
+	return "", false
 }
 
-func ValidToken(r *http.Request) error {
-	tokenString := ExtractToken(r)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("API_SECRET")), nil
-	})
+// parseToken only accepts access tokens, other tokens we sign (such as mfa pending tokens) are not authorized
+func parseToken(tokenString string) (*jwt.Token, error) {
+	token, err := jwt.Parse(tokenString, verificationKey)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		Pretty(claims)
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["authorized"] != true {
+		return nil, errors.New("Token is not an access token")
 	}
-	return nil
+	return token, nil
+}
+
+func ValidToken(r *http.Request) error {
+	_, err := Authenticate(r)
+	return err
 }
 
 func ExtractTokenID(r *http.Request) (uint32, error) {
-	tokenString := ExtractToken(r)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("API_SECRET")), nil
-	})
+	p, err := Authenticate(r)
 	if err != nil {
 		return 0, err
 	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if ok && token.Valid {
-		uid, err := strconv.ParseUint(fmt.Sprintf("%.0f", claims["userId"]), 10, 32)
-		if err != nil {
-			return 0, err
-		}
-		return uint32(uid), nil
-	}
-	return 0, nil
+	return p.UserID, nil
 }
 
 // Pretty display the claims in a pretty/nice format on the terminal
@@ -82,4 +102,4 @@ func Pretty(data interface{}) {
 	}
 
 	fmt.Println(string(b))
-}
\ No newline at end of file
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	totpPeriod       = 30
+	totpSkew         = 1               // Codes from one period either side are accepted for clock drift
+	MFATokenLifetime = time.Minute * 5 // Time allowed between the password and the second factor
+)
+
+// GenerateTOTPSecret creates a new TOTP secret for the account and its otpauth:// provisioning URI
+func GenerateTOTPSecret(accountName string) (string, string, error) {
+	issuer := os.Getenv("APP_NAME")
+	if issuer == "" {
+		issuer = "Medium API"
+	}
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: accountName,
+		Period:      totpPeriod,
+		Algorithm:   otp.AlgorithmSHA1,
+		Digits:      otp.DigitsSix,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return key.Secret(), key.URL(), nil
+}
+
+// ValidateTOTP checks the code against the secret and returns the time step it belongs to.
+// Callers store the step and reject codes from a step at or before it, so a code can not be replayed
+func ValidateTOTP(secret, code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0), totp.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// CreateMFAToken issues the short lived token handed out after a correct password when a second factor is still needed
+func CreateMFAToken(userId uint32) (string, error) {
+	jti, err := GenerateOpaqueToken(16)
+	if err != nil {
+		return "", err
+	}
+	claims := jwt.MapClaims{}
+	claims["authorized"] = false
+	claims["mfa_pending"] = true
+	claims["jti"] = jti
+	claims["userId"] = userId
+	claims["exp"] = time.Now().Add(MFATokenLifetime).Unix()
+	return signToken(claims)
+}
+
+// MFAToken is a validated mfa pending token, it can be exchanged for a token pair once
+type MFAToken struct {
+	UserID    uint32
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+// ParseMFAToken validates a mfa pending token that has not been used yet
+func ParseMFAToken(tokenString string) (*MFAToken, error) {
+	token, err := jwt.Parse(tokenString, verificationKey)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid || claims["mfa_pending"] != true {
+		return nil, errors.New("Token is not a mfa token")
+	}
+	jti, ok := claims["jti"].(string)
+	if !ok || jti == "" {
+		return nil, errors.New("Token is not a mfa token")
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("Token is not a mfa token")
+	}
+	uid, err := strconv.ParseUint(fmt.Sprintf("%.0f", claims["userId"]), 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := revocations.IsRevoked(jti)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errors.New("Token has already been used")
+	}
+	return &MFAToken{UserID: uint32(uid), TokenID: jti, ExpiresAt: time.Unix(int64(exp), 0)}, nil
+}
+
+// Use marks the token as used, it is kept in the revocation store until it would have expired anyway
+func (t *MFAToken) Use() error {
+	return revocations.Revoke(t.TokenID, t.ExpiresAt)
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/responses"
+	"github.com/mmosoroohh/Go_Medium_API/api/utils/formaterror"
+)
+
+func (server *Server) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	uid := principal.UserID
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	apiKey := models.APIKey{}
+	err = json.Unmarshal(body, &apiKey)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	apiKey.Prepare()
+	err = apiKey.Validate()
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	key, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	apiKey.UserID = uid
+	apiKey.Prefix = prefix
+	apiKey.KeyHash = auth.HashToken(key)
+	keyCreated, err := apiKey.SaveAPIKey(server.DB)
+	if err != nil {
+		formattedError := formaterror.FormatError(err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, formattedError)
+		return
+	}
+
+	// This is the only time the key is ever shown
+	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.URL.Path, keyCreated.ID))
+	responses.JSON(w, http.StatusCreated, struct {
+		*models.APIKey
+		Key string `json:"key"`
+	}{
+		APIKey: keyCreated,
+		Key:    key,
+	})
+}
+
+func (server *Server) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	uid := principal.UserID
+	apiKey := models.APIKey{}
+	keys, err := apiKey.UserAPIKeys(server.DB, uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, keys)
+}
+
+func (server *Server) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	kid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	uid := principal.UserID
+	apiKey := models.APIKey{}
+	_, err = apiKey.RevokeAPIKey(server.DB, kid, uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+	w.Header().Set("Entity", fmt.Sprintf("%d", kid))
+	responses.JSON(w, http.StatusNoContent, "")
+}
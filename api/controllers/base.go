@@ -1,21 +1,57 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/mysql"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 	"github.com/joho/godotenv"
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/mailer"
+	"github.com/mmosoroohh/Go_Medium_API/api/markdown"
 	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/oauth"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 )
 
 type Server struct {
-	DB     *gorm.DB
-	Router *mux.Router
+	DB          *gorm.DB
+	Router      *mux.Router
+	Revocations auth.RevocationStore
+	Mailer      mailer.Mailer
+
+	LoginAttempts   auth.AttemptTracker // Failed logins per account
+	LoginIPAttempts auth.AttemptTracker // Failed logins per client address
+
+	// Now is the clock deciding when scheduled posts are due, it defaults to time.Now
+	Now func() time.Time
+
+	// Identity providers users can sign in with, by name
+	OAuthProviders map[string]*oauth.Provider
+
+	// PasswordResetURL is the page of the frontend where users choose a new password, password reset mails link
+	// to it with the token added as ?token=
+	PasswordResetURL string
+
+	// RequireVerifiedEmail stops users from posting until they verified their email address
+	RequireVerifiedEmail bool
+
+	// RevisionRetention decides how many old versions of a post are kept
+	RevisionRetention models.RevisionRetention
+
+	// TrashRetention is how long deleted posts and users can be restored before they are purged, zero keeps them forever
+	TrashRetention time.Duration
+
+	// SearchEngine answers searches, the full-text search of the database where it has one
+	SearchEngine models.SearchEngine
 }
 
 func (server *Server) Initialize() {
@@ -53,14 +89,118 @@ func (server *Server) Initialize() {
 		}
 	}
 
-	server.DB.Debug().AutoMigrate(&models.User{}, &models.Post{}) // Database migration
+	// Migrations of posts from before a column existed run before AutoMigrate adds the column
+	err = models.MigrateContent(server.DB)
+	if err != nil {
+		log.Fatal("Cannot migrate post content: ", err)
+	}
+
+	err = models.MigrateStatus(server.DB)
+	if err != nil {
+		log.Fatal("Cannot migrate post status: ", err)
+	}
+
+	server.DB.Debug().AutoMigrate(&models.User{}, &models.Post{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.PasswordReset{}, &models.EmailVerification{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.APIKey{}, &models.Identity{}, &models.PostSlug{}, &models.Tag{}, &models.PostTag{}, &models.PostRevision{}, &models.Clap{}, &models.Comment{}, &models.Follow{}, &models.ReadingList{}, &models.Bookmark{}) // Database migration
+
+	err = models.BackfillSlugs(server.DB)
+	if err != nil {
+		log.Fatal("Cannot backfill post slugs: ", err)
+	}
+
+	err = models.MigrateSearch(server.DB)
+	if err != nil {
+		log.Fatal("Cannot create the search indexes: ", err)
+	}
+	server.SearchEngine = models.NewSearchEngine(server.DB)
+
+	markdown.SetEmbedHosts(markdown.EmbedHostsFromEnv())
+
+	server.RequireVerifiedEmail = os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true"
+
+	// e.g. PASSWORD_RESET_URL=https://example.com/reset-password, without it reset mails only carry the token
+	server.PasswordResetURL = os.Getenv("PASSWORD_RESET_URL")
+
+	// e.g. REVISIONS_KEEP=50 and REVISIONS_MAX_AGE=2160h, by default every revision is kept
+	if n, err := strconv.Atoi(os.Getenv("REVISIONS_KEEP")); err == nil && n > 0 {
+		server.RevisionRetention.Keep = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("REVISIONS_MAX_AGE")); err == nil && d > 0 {
+		server.RevisionRetention.MaxAge = d
+	}
+
+	// TRASH_RETENTION_DAYS=0 turns purging off
+	server.TrashRetention = 30 * 24 * time.Hour
+	if n, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS")); err == nil && n >= 0 {
+		server.TrashRetention = time.Duration(n) * 24 * time.Hour
+	}
+
+	auth.SetSessionConfig(auth.SessionConfigFromEnv())
+
+	err = auth.LoadKeysFromEnv()
+	if err != nil {
+		log.Fatal("Cannot load the token signing keys: ", err)
+	}
+
+	// A single address may be shared by many users (NAT, offices), so it gets more room than an account
+	ipPolicy := auth.DefaultThrottlePolicy()
+	ipPolicy.FreeAttempts, ipPolicy.MaxAttempts = 10, 50
+	server.LoginAttempts = auth.NewMemoryAttemptTracker(auth.DefaultThrottlePolicy().WithEnv("LOGIN_MAX_ATTEMPTS"))
+	server.LoginIPAttempts = auth.NewMemoryAttemptTracker(ipPolicy.WithEnv("LOGIN_MAX_ATTEMPTS_PER_IP"))
+
+	server.OAuthProviders, err = oauth.ProvidersFromEnv(context.Background())
+	if err != nil {
+		log.Fatal("Cannot set up the identity providers: ", err)
+	}
+
+	server.Mailer, err = mailer.FromEnv()
+	if err != nil {
+		log.Fatal("Cannot set up the mailer: ", err)
+	}
+
+	// Revoked access tokens are kept in memory unless they need to be shared between instances
+	if os.Getenv("REVOCATION_STORE") == "memory" {
+		server.Revocations = auth.NewMemoryRevocationStore()
+	} else {
+		server.Revocations = models.NewDBRevocationStore(server.DB)
+	}
+	auth.SetRevocationStore(server.Revocations)
+	auth.SetAPIKeyStore(models.NewDBAPIKeyStore(server.DB))
+	auth.SetAccountStore(models.NewDBAccountStore(server.DB))
+	auth.StartRevocationGC(server.Revocations, time.Minute*10)
 
 	server.Router = mux.NewRouter()
 
 	server.initializeRoutes()
 }
 
+func (server *Server) now() time.Time {
+	if server.Now != nil {
+		return server.Now()
+	}
+	return time.Now()
+}
+
+// Run serves the API until the process is interrupted, then lets requests in flight finish
 func (server *Server) Run(addr string) {
+	httpServer := &http.Server{Addr: addr, Handler: server.Router}
+	shutdown := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+		err := httpServer.Shutdown(ctx)
+		if err != nil {
+			log.Printf("Shutting down the server failed: %v", err)
+		}
+		close(shutdown)
+	}()
+
 	fmt.Println("Listening to port 8080")
-	log.Fatal(http.ListenAndServe(addr, server.Router))
+	err := httpServer.ListenAndServe()
+	if err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-shutdown
 }
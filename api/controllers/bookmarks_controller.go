@@ -0,0 +1,347 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/responses"
+)
+
+// defaultList loads the reading list of the user asking
+func (server *Server) defaultList(w http.ResponseWriter, r *http.Request) (*models.ReadingList, bool) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return nil, false
+	}
+	list := models.ReadingList{}
+	_, err = list.DefaultList(server.DB, principal.UserID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return &list, true
+}
+
+// readingList loads the list of the request. Private lists are only there for their owner, and only the owner
+// may change a list
+func (server *Server) readingList(w http.ResponseWriter, r *http.Request, change bool) (*models.ReadingList, bool) {
+	vars := mux.Vars(r)
+	lid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	list := models.ReadingList{}
+	_, err = list.FindList(server.DB, lid)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, errors.New("List Not Found"))
+		return nil, false
+	}
+	owner := server.viewerID(r) == list.UserID
+	if !list.Public && !owner {
+		responses.ERROR(w, http.StatusNotFound, errors.New("List Not Found"))
+		return nil, false
+	}
+	if change && !owner {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return nil, false
+	}
+	// The lists of users in the trash are gone with them
+	err = server.DB.Debug().Model(models.User{}).Where("id = ?", list.UserID).Take(&models.User{}).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, errors.New("List Not Found"))
+		return nil, false
+	}
+	return &list, true
+}
+
+// listPage answers with a page of the list's bookmarks, see paging
+func (server *Server) listPage(w http.ResponseWriter, r *http.Request, list *models.ReadingList) {
+	page, err := paging(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	bookmarks, info, err := list.Bookmarks(server.DB, server.now(), page)
+	if err != nil {
+		if err.Error() == "Invalid Sort" {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	posts := make([]models.Post, len(*bookmarks))
+	for i, bookmark := range *bookmarks {
+		posts[i] = *bookmark.Post
+	}
+	err = server.markPosts(r, posts)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	for i := range *bookmarks {
+		(*bookmarks)[i].Post = &posts[i]
+	}
+	responses.JSON(w, http.StatusOK, newPage(r, bookmarks, info))
+}
+
+// saveToList saves a post to a list, the user has to be able to read it
+func (server *Server) saveToList(w http.ResponseWriter, r *http.Request, list *models.ReadingList, pid uint64) {
+	post, ok := server.findReadablePost(w, r, pid)
+	if !ok {
+		return
+	}
+	bookmark, err := list.AddPost(server.DB, post.ID)
+	if err != nil {
+		if err.Error() == "Already In List" {
+			responses.ERROR(w, http.StatusConflict, err)
+			return
+		}
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusCreated, bookmark)
+}
+
+func (server *Server) removeFromList(w http.ResponseWriter, list *models.ReadingList, pid uint64) {
+	err := list.RemovePost(server.DB, pid)
+	if err != nil {
+		if err.Error() == "Not In List" {
+			responses.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusNoContent, "")
+}
+
+// BookmarkPost saves a post to the reading list of the user asking
+func (server *Server) BookmarkPost(w http.ResponseWriter, r *http.Request) {
+	list, ok := server.defaultList(w, r)
+	if !ok {
+		return
+	}
+	pid, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	server.saveToList(w, r, list, pid)
+}
+
+// UnbookmarkPost takes a post off the reading list of the user asking
+func (server *Server) UnbookmarkPost(w http.ResponseWriter, r *http.Request) {
+	list, ok := server.defaultList(w, r)
+	if !ok {
+		return
+	}
+	pid, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	server.removeFromList(w, list, pid)
+}
+
+// GetBookmarks lists the reading list of the user asking, latest saved first
+func (server *Server) GetBookmarks(w http.ResponseWriter, r *http.Request) {
+	list, ok := server.defaultList(w, r)
+	if !ok {
+		return
+	}
+	server.listPage(w, r, list)
+}
+
+// GetMyLists lists all lists of the user asking, private ones included
+func (server *Server) GetMyLists(w http.ResponseWriter, r *http.Request) {
+	list, ok := server.defaultList(w, r)
+	if !ok {
+		return
+	}
+	lists, err := list.UserLists(server.DB, list.UserID, true)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, lists)
+}
+
+// GetUserLists lists the public lists of a user, or all of them when the user asks for their own
+func (server *Server) GetUserLists(w http.ResponseWriter, r *http.Request) {
+	user, ok := server.pathUser(w, r)
+	if !ok {
+		return
+	}
+	list := models.ReadingList{}
+	lists, err := list.UserLists(server.DB, user.ID, server.viewerID(r) == user.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, lists)
+}
+
+// CreateList makes a new list for the user asking, {"name": "...", "public": true} shares it with everyone
+func (server *Server) CreateList(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	list := models.ReadingList{}
+	err = json.Unmarshal(body, &list)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	list.Prepare()
+	list.UserID = principal.UserID
+	err = list.Validate()
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	// The reading list is made first so it keeps its name
+	_, ok := server.defaultList(w, r)
+	if !ok {
+		return
+	}
+	listCreated, err := list.SaveList(server.DB)
+	if err != nil {
+		if err.Error() == "List Already Exists" {
+			responses.ERROR(w, http.StatusConflict, err)
+			return
+		}
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusCreated, listCreated)
+}
+
+// GetList shows a list with how many posts it holds
+func (server *Server) GetList(w http.ResponseWriter, r *http.Request) {
+	list, ok := server.readingList(w, r, false)
+	if !ok {
+		return
+	}
+	responses.JSON(w, http.StatusOK, list)
+}
+
+// GetListPosts lists the posts saved to a list, latest saved first
+func (server *Server) GetListPosts(w http.ResponseWriter, r *http.Request) {
+	list, ok := server.readingList(w, r, false)
+	if !ok {
+		return
+	}
+	server.listPage(w, r, list)
+}
+
+// UpdateList renames a list or makes it public or private
+func (server *Server) UpdateList(w http.ResponseWriter, r *http.Request) {
+	list, ok := server.readingList(w, r, true)
+	if !ok {
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	request := struct {
+		Name   *string `json:"name"`
+		Public *bool   `json:"public"`
+	}{}
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	name, public := list.Name, list.Public
+	if request.Name != nil {
+		name = *request.Name
+	}
+	if request.Public != nil {
+		public = *request.Public
+	}
+	listUpdated, err := list.UpdateList(server.DB, name, public)
+	if err != nil {
+		switch err.Error() {
+		case "Name Required", "Name Too Long", "Cannot Rename Reading List":
+			responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		case "List Already Exists":
+			responses.ERROR(w, http.StatusConflict, err)
+		default:
+			responses.ERROR(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	responses.JSON(w, http.StatusOK, listUpdated)
+}
+
+// DeleteList deletes a list, the reading list itself cannot be deleted
+func (server *Server) DeleteList(w http.ResponseWriter, r *http.Request) {
+	list, ok := server.readingList(w, r, true)
+	if !ok {
+		return
+	}
+	err := list.DeleteList(server.DB)
+	if err != nil {
+		if err.Error() == "Cannot Delete Reading List" {
+			responses.ERROR(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusNoContent, "")
+}
+
+// AddListPost saves a post to a list, {"post_id": 1}
+func (server *Server) AddListPost(w http.ResponseWriter, r *http.Request) {
+	list, ok := server.readingList(w, r, true)
+	if !ok {
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	request := struct {
+		PostID uint64 `json:"post_id"`
+	}{}
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	server.saveToList(w, r, list, request.PostID)
+}
+
+// RemoveListPost takes a post off a list
+func (server *Server) RemoveListPost(w http.ResponseWriter, r *http.Request) {
+	list, ok := server.readingList(w, r, true)
+	if !ok {
+		return
+	}
+	pid, err := strconv.ParseUint(mux.Vars(r)["pid"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	server.removeFromList(w, list, pid)
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/responses"
+)
+
+// ClapResult is what a clap leaves behind, the user's claps on the post and the post's total
+type ClapResult struct {
+	PostID    uint64 `json:"post_id"`
+	Claps     int    `json:"claps"`
+	ClapCount int64  `json:"clap_count"`
+}
+
+// viewerID is the user asking, if they sent credentials, for the parts of public responses that are about them
+func (server *Server) viewerID(r *http.Request) uint32 {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		return 0
+	}
+	return principal.UserID
+}
+
+// markPosts sets what the posts say about the user asking, whether they clapped for and bookmarked them
+func (server *Server) markPosts(r *http.Request, posts []models.Post) error {
+	uid := server.viewerID(r)
+	err := models.MarkClapped(server.DB, posts, uid)
+	if err != nil {
+		return err
+	}
+	return models.MarkBookmarked(server.DB, posts, uid)
+}
+
+func (server *Server) markPost(r *http.Request, post *models.Post) error {
+	posts := []models.Post{*post}
+	err := server.markPosts(r, posts)
+	post.Clapped, post.Bookmarked = posts[0].Clapped, posts[0].Bookmarked
+	return err
+}
+
+func (server *Server) clapResult(w http.ResponseWriter, pid uint64, claps int) {
+	post := models.Post{}
+	err := server.DB.Debug().Model(models.Post{}).Where("id = ?", pid).Take(&post).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, ClapResult{PostID: pid, Claps: claps, ClapCount: post.ClapCount})
+}
+
+// ClapPost claps for a post, {"claps": 10} claps several times at once. Each user has up to 50 claps per post,
+// claps past that are dropped
+func (server *Server) ClapPost(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	post, ok := server.readablePost(w, r)
+	if !ok {
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	request := struct {
+		Claps int `json:"claps"`
+	}{Claps: 1}
+	if len(body) > 0 {
+		err = json.Unmarshal(body, &request)
+		if err != nil {
+			responses.ERROR(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+	}
+	clap := models.Clap{}
+	_, err = clap.AddClaps(server.DB, post.ID, principal.UserID, request.Claps)
+	if err != nil {
+		switch err.Error() {
+		case "Invalid Number Of Claps", "Clap Limit Reached":
+			responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		case "Too Many Concurrent Claps":
+			responses.ERROR(w, http.StatusConflict, err)
+		default:
+			responses.ERROR(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	server.clapResult(w, post.ID, clap.Count)
+}
+
+// UnclapPost takes back all of the user's claps on a post
+func (server *Server) UnclapPost(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	post, ok := server.readablePost(w, r)
+	if !ok {
+		return
+	}
+	clap := models.Clap{}
+	_, err = clap.RemoveClaps(server.DB, post.ID, principal.UserID)
+	if err != nil {
+		if err.Error() == "Too Many Concurrent Claps" {
+			responses.ERROR(w, http.StatusConflict, err)
+			return
+		}
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	server.clapResult(w, post.ID, 0)
+}
+
+// GetClaps lists who clapped for a post and how often
+func (server *Server) GetClaps(w http.ResponseWriter, r *http.Request) {
+	post, ok := server.readablePost(w, r)
+	if !ok {
+		return
+	}
+	clappers, err := models.Clappers(server.DB, post.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, clappers)
+}
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/responses"
+)
+
+// findComment loads the comment of the request along with its post
+func (server *Server) findComment(w http.ResponseWriter, r *http.Request) (*models.Post, *models.Comment, bool) {
+	post, ok := server.readablePost(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+	vars := mux.Vars(r)
+	cid, err := strconv.ParseUint(vars["cid"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return nil, nil, false
+	}
+	comment := models.Comment{}
+	_, err = comment.FindComment(server.DB, post.ID, cid)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return nil, nil, false
+	}
+	return post, &comment, true
+}
+
+// readComment decodes the comment in the request body
+func readComment(w http.ResponseWriter, r *http.Request) (*models.Comment, bool) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return nil, false
+	}
+	comment := models.Comment{}
+	err = json.Unmarshal(body, &comment)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return nil, false
+	}
+	return &comment, true
+}
+
+// GetComments lists the comments on a post as threads, ?view=flat lists them in reading order with their depth.
+// The content of hidden comments is left out, except for the post's author, editors and whoever wrote them
+func (server *Server) GetComments(w http.ResponseWriter, r *http.Request) {
+	post, ok := server.readablePost(w, r)
+	if !ok {
+		return
+	}
+	view := r.URL.Query().Get("view")
+	if view != "" && view != "nested" && view != "flat" {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("Invalid View"))
+		return
+	}
+	comment := models.Comment{}
+	comments, err := comment.PostComments(server.DB, post.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	principal, err := auth.PrincipalFromRequest(r)
+	moderator := err == nil && principal.CanActOn(post.AuthorID, auth.PermPostsUpdateAny)
+	for i := range *comments {
+		c := &(*comments)[i]
+		if c.Hidden && !moderator && (err != nil || principal.UserID != c.AuthorID) {
+			c.Content, c.ContentHTML = "", ""
+		}
+	}
+	threads := models.ThreadComments(*comments)
+	if view == "flat" {
+		responses.JSON(w, http.StatusOK, models.FlattenComments(threads))
+		return
+	}
+	responses.JSON(w, http.StatusOK, threads)
+}
+
+// CreateComment comments on a post, or with "parent_id" replies to one of its comments
+func (server *Server) CreateComment(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	post, ok := server.readablePost(w, r)
+	if !ok {
+		return
+	}
+	comment, ok := readComment(w, r)
+	if !ok {
+		return
+	}
+	comment.Prepare()
+	comment.PostID = post.ID
+	comment.AuthorID = principal.UserID
+	err = comment.Validate()
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if server.RequireVerifiedEmail {
+		author := models.User{}
+		err = server.DB.Debug().Model(models.User{}).Where("id = ?", principal.UserID).Take(&author).Error
+		if err != nil || !author.EmailVerified() {
+			responses.ERROR(w, http.StatusForbidden, errors.New("Email Not Verified"))
+			return
+		}
+	}
+	commentCreated, err := comment.SaveComment(server.DB)
+	if err != nil {
+		switch err.Error() {
+		case "Parent Comment Not Found", "Thread Too Deep":
+			responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		default:
+			responses.ERROR(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.URL.Path, commentCreated.ID))
+	responses.JSON(w, http.StatusCreated, commentCreated)
+}
+
+// UpdateComment changes what a comment says, only its author or an editor may
+func (server *Server) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	_, comment, ok := server.findComment(w, r)
+	if !ok {
+		return
+	}
+	if !principal.CanActOn(comment.AuthorID, auth.PermPostsUpdateAny) {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	if comment.Deleted {
+		responses.ERROR(w, http.StatusNotFound, errors.New("Comment Not Found"))
+		return
+	}
+	commentUpdate, ok := readComment(w, r)
+	if !ok {
+		return
+	}
+	commentUpdate.Prepare()
+	commentUpdate.ID, commentUpdate.PostID, commentUpdate.ParentID = comment.ID, comment.PostID, comment.ParentID
+	commentUpdate.AuthorID, commentUpdate.Depth, commentUpdate.Hidden = comment.AuthorID, comment.Depth, comment.Hidden
+	commentUpdate.CreatedAt = comment.CreatedAt
+	err = commentUpdate.Validate()
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	commentUpdated, err := commentUpdate.UpdateComment(server.DB)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, commentUpdated)
+}
+
+// DeleteComment deletes a comment, only its author or an editor may
+func (server *Server) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	_, comment, ok := server.findComment(w, r)
+	if !ok {
+		return
+	}
+	if !principal.CanActOn(comment.AuthorID, auth.PermPostsDeleteAny) {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	err = comment.DeleteComment(server.DB)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Entity", fmt.Sprintf("%d", comment.ID))
+	responses.JSON(w, http.StatusNoContent, "")
+}
+
+// HideComment hides a comment on the post, for the post's author to moderate the responses
+func (server *Server) HideComment(w http.ResponseWriter, r *http.Request) {
+	server.setCommentHidden(w, r, true)
+}
+
+// UnhideComment shows a hidden comment again
+func (server *Server) UnhideComment(w http.ResponseWriter, r *http.Request) {
+	server.setCommentHidden(w, r, false)
+}
+
+func (server *Server) setCommentHidden(w http.ResponseWriter, r *http.Request, hidden bool) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	post, comment, ok := server.findComment(w, r)
+	if !ok {
+		return
+	}
+	if !principal.CanActOn(post.AuthorID, auth.PermPostsUpdateAny) {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	commentUpdated, err := comment.SetHidden(server.DB, hidden)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, commentUpdated)
+}
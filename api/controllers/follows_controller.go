@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/responses"
+)
+
+// pathUser loads the user of the request path, for following them and their lists
+func (server *Server) pathUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	vars := mux.Vars(r)
+	uid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	user := models.User{}
+	err = server.DB.Debug().Model(models.User{}).Where("id = ?", uid).Take(&user).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, errors.New("User Not Found"))
+		return nil, false
+	}
+	return &user, true
+}
+
+// FollowUser makes the user asking follow another user
+func (server *Server) FollowUser(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	user, ok := server.pathUser(w, r)
+	if !ok {
+		return
+	}
+	follow := models.Follow{}
+	_, err = follow.FollowUser(server.DB, principal.UserID, user.ID)
+	if err != nil {
+		switch err.Error() {
+		case "Cannot Follow Yourself":
+			responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		case "Already Following":
+			responses.ERROR(w, http.StatusConflict, err)
+		default:
+			responses.ERROR(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	responses.JSON(w, http.StatusCreated, follow)
+}
+
+// UnfollowUser makes the user asking stop following another user
+func (server *Server) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	user, ok := server.pathUser(w, r)
+	if !ok {
+		return
+	}
+	follow := models.Follow{}
+	err = follow.UnfollowUser(server.DB, principal.UserID, user.ID)
+	if err != nil {
+		if err.Error() == "Not Following" {
+			responses.ERROR(w, http.StatusNotFound, err)
+			return
+		}
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusNoContent, "")
+}
+
+// GetFollowers lists who follows a user, with how many they are
+func (server *Server) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	user, ok := server.pathUser(w, r)
+	if !ok {
+		return
+	}
+	followers, err := models.Followers(server.DB, user.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, followers)
+}
+
+// GetFollowing lists whom a user follows, with how many they are
+func (server *Server) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	user, ok := server.pathUser(w, r)
+	if !ok {
+		return
+	}
+	following, err := models.Following(server.DB, user.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, following)
+}
+
+// GetFeed lists the posts of the authors the user follows, latest first, a page at a time
+func (server *Server) GetFeed(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	page, err := paging(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	post := models.Post{}
+	posts, info, err := post.FeedPosts(server.DB, principal.UserID, server.now(), page)
+	if err != nil {
+		if err.Error() == "Invalid Sort" {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	err = server.markPosts(r, *posts)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, newPage(r, posts, info))
+}
@@ -0,0 +1,14 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/responses"
+)
+
+// JWKS publishes the public keys access tokens can be verified with
+func (server *Server) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	responses.JSON(w, http.StatusOK, auth.PublicJWKS())
+}
@@ -2,28 +2,52 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/mmosoroohh/Go_Medium_API/api/auth"
 	"github.com/mmosoroohh/Go_Medium_API/api/models"
 	"github.com/mmosoroohh/Go_Medium_API/api/responses"
 	"github.com/mmosoroohh/Go_Medium_API/api/utils/formaterror"
 	"golang.org/x/crypto/bcrypt"
 	"io/ioutil"
+	"math"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func (server *Server) SignIn(email, password string) (string, error) {
+func (server *Server) SignIn(email, password string) (*auth.TokenDetails, error) {
 	var err error
 	user := models.User{}
 
 	err = server.DB.Debug().Model(models.User{}).Where("email = ?", email).Take(&user).Error
 	if err != nil {
-		return "", err
+		return &auth.TokenDetails{}, err
 	}
 	err = models.VerifyPassword(user.Password, password)
 	if err != nil && err == bcrypt.ErrMismatchedHashAndPassword {
-		return "", err
+		return &auth.TokenDetails{}, err
 	}
-	return auth.CreateToken(user.ID)
+	return server.signInUser(user.ID)
+}
+
+// signInUser starts a session for a user whose first factor checked out, asking for the second one if enabled
+func (server *Server) signInUser(uid uint32) (*auth.TokenDetails, error) {
+	twoFactor := models.TwoFactor{}
+	enabled, err := twoFactor.TwoFactorEnabled(server.DB, uid)
+	if err != nil {
+		return &auth.TokenDetails{}, err
+	}
+	if enabled {
+		mfaToken, err := auth.CreateMFAToken(uid)
+		if err != nil {
+			return &auth.TokenDetails{}, err
+		}
+		return &auth.TokenDetails{MFARequired: true, MFAToken: mfaToken}, nil
+	}
+	return server.CreateTokenPair(uid, "")
 }
 
 func (server *Server) Login(w http.ResponseWriter, r *http.Request) {
@@ -39,17 +63,125 @@ func (server *Server) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Browsers ask for a cookie session so the tokens never reach their scripts
+	session := struct {
+		Session bool `json:"session"`
+	}{}
+	_ = json.Unmarshal(body, &session)
+
 	user.Prepare()
 	err = user.Validate("login")
 	if err != nil {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
 		return
 	}
-	token, err := server.SignIn(user.Email, user.Password)
+
+	// Both the account and the client are throttled, so neither guessing one password
+	// nor trying one password against many accounts gets far
+	accountKey := "account:" + strings.ToLower(user.Email)
+	ipKey := "ip:" + clientIP(r)
+	now := time.Now()
+	wait := server.LoginAttempts.Reserve(accountKey, now)
+	ipWait := server.LoginIPAttempts.Reserve(ipKey, now)
+	if wait > 0 || ipWait > 0 {
+		// Only the attempts let through count
+		if wait == 0 {
+			server.LoginAttempts.Release(accountKey)
+		}
+		if ipWait == 0 {
+			server.LoginIPAttempts.Release(ipKey)
+		}
+		if ipWait > wait {
+			wait = ipWait
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		responses.ERROR(w, http.StatusTooManyRequests, errors.New("Too Many Login Attempts"))
+		return
+	}
+
+	// The attempt already counts as failed, so guesses sent at once cannot all get to the password check
+	tokens, err := server.SignIn(user.Email, user.Password)
 	if err != nil {
 		formattedError := formaterror.FormatError(err.Error())
 		responses.ERROR(w, http.StatusUnprocessableEntity, formattedError)
 		return
 	}
-	responses.JSON(w, http.StatusOK, token)
+	server.LoginAttempts.Reset(accountKey)
+	server.LoginIPAttempts.Release(ipKey)
+	server.writeTokens(w, tokens, session.Session)
+}
+
+// writeTokens responds with the token pair, moving it into cookies when a cookie session was asked for
+func (server *Server) writeTokens(w http.ResponseWriter, tokens *auth.TokenDetails, session bool) {
+	if session && auth.CookieSessionsEnabled() && !tokens.MFARequired {
+		var err error
+		tokens, err = auth.SetSessionCookies(w, tokens)
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	responses.JSON(w, http.StatusOK, tokens)
+}
+
+func (server *Server) Logout(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	// The refresh token is optional, when given the whole session it belongs to is ended as well
+	request := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{}
+	if len(body) > 0 {
+		err = json.Unmarshal(body, &request)
+		if err != nil {
+			responses.ERROR(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+	}
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	uid := principal.UserID
+	if request.RefreshToken == "" && principal.Cookie {
+		request.RefreshToken = auth.ExtractSessionCookie(r, auth.RefreshCookieName)
+	}
+	err = auth.RevokeToken(principal)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	if request.RefreshToken != "" {
+		refreshToken := models.RefreshToken{}
+		_, err = refreshToken.FindRefreshToken(server.DB, auth.HashToken(request.RefreshToken))
+		if err == nil && refreshToken.UserID == uid {
+			_, err = refreshToken.RevokeTokenFamily(server.DB, refreshToken.FamilyID)
+			if err != nil {
+				responses.ERROR(w, http.StatusInternalServerError, err)
+				return
+			}
+		}
+	}
+	if principal.Cookie {
+		auth.ClearSessionCookies(w)
+	}
+	responses.JSON(w, http.StatusNoContent, "")
+}
+
+// clientIP is the address the request came from, X-Forwarded-For is only trusted behind a proxy (TRUST_PROXY=true)
+func clientIP(r *http.Request) string {
+	if os.Getenv("TRUST_PROXY") == "true" {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
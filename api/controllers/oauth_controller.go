@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/oauth"
+	"github.com/mmosoroohh/Go_Medium_API/api/responses"
+)
+
+var (
+	errIdentityConflict = errors.New("An Account With This Email Already Exists")
+	usernameChars       = regexp.MustCompile(`[^a-z0-9_]+`)
+)
+
+// OAuthLogin sends the user to the identity provider, ?session=true finishes with a cookie session
+func (server *Server) OAuthLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := server.OAuthProviders[mux.Vars(r)["provider"]]
+	if !ok {
+		responses.ERROR(w, http.StatusNotFound, errors.New("Provider Not Found"))
+		return
+	}
+	state := auth.OAuthState{Provider: provider.Name, Session: r.URL.Query().Get("session") == "true"}
+	var err error
+	for _, value := range []*string{&state.State, &state.Nonce, &state.Verifier} {
+		*value, err = auth.GenerateOpaqueToken(32)
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	token, err := auth.CreateOAuthStateToken(state)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	auth.SetOAuthStateCookie(w, token)
+	http.Redirect(w, r, provider.AuthCodeURL(state.State, state.Nonce, state.Verifier), http.StatusFound)
+}
+
+// OAuthCallback is where the identity provider sends the user back to, signing them in to the linked account
+func (server *Server) OAuthCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := server.OAuthProviders[mux.Vars(r)["provider"]]
+	if !ok {
+		responses.ERROR(w, http.StatusNotFound, errors.New("Provider Not Found"))
+		return
+	}
+	auth.ClearOAuthStateCookie(w)
+	state, err := auth.ParseOAuthStateToken(auth.ExtractOAuthStateCookie(r))
+	query := r.URL.Query()
+	if err != nil || state.Provider != provider.Name || subtle.ConstantTimeCompare([]byte(state.State), []byte(query.Get("state"))) != 1 {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Invalid State"))
+		return
+	}
+	if query.Get("error") != "" {
+		responses.ERROR(w, http.StatusUnauthorized, fmt.Errorf("Sign In Failed: %s", query.Get("error")))
+		return
+	}
+	identity, err := provider.Exchange(r.Context(), query.Get("code"), state.Verifier, state.Nonce)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	uid, err := server.userForIdentity(identity)
+	if err == errIdentityConflict {
+		responses.ERROR(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	tokens, err := server.signInUser(uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	server.writeTokens(w, tokens, state.Session)
+}
+
+func (server *Server) GetIdentities(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	identity := models.Identity{}
+	identities, err := identity.UserIdentities(server.DB, principal.UserID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, identities)
+}
+
+// userForIdentity finds the user linked to the identity. Unknown identities are linked to the account with the same
+// email, but only if both the provider and the account verified that email, and otherwise get a new account. Anyone
+// can sign up with an email they do not own, an unverified account may not belong to the owner of the email
+func (server *Server) userForIdentity(identity *oauth.Identity) (uint32, error) {
+	linked := models.Identity{}
+	_, err := linked.FindIdentity(server.DB, identity.Provider, identity.Subject)
+	if err == nil {
+		return linked.UserID, nil
+	}
+	if identity.Email == "" {
+		return 0, errors.New("Email Required")
+	}
+
+	user := models.User{}
+	err = server.DB.Debug().Model(models.User{}).Where("email = ?", identity.Email).Take(&user).Error
+	if err == nil && (!identity.EmailVerified || !user.EmailVerified()) {
+		return 0, errIdentityConflict
+	}
+	if gorm.IsRecordNotFoundError(err) {
+		user, err = server.createIdentityUser(identity)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	linked = models.Identity{
+		UserID:   user.ID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}
+	_, err = linked.SaveIdentity(server.DB)
+	if err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
+
+// createIdentityUser signs up a user from their identity. They get a random password, which they can replace
+// through the password reset if they ever want to log in without the provider
+func (server *Server) createIdentityUser(identity *oauth.Identity) (models.User, error) {
+	password, err := auth.GenerateOpaqueToken(32)
+	if err != nil {
+		return models.User{}, err
+	}
+	base := identity.Name
+	if base == "" {
+		base = strings.Split(identity.Email, "@")[0]
+	}
+	base = strings.Trim(usernameChars.ReplaceAllString(strings.ToLower(base), "_"), "_")
+	if base == "" {
+		base = "user"
+	}
+	username := base
+	for n := 2; ; n++ {
+		count := 0
+		err = server.DB.Debug().Model(models.User{}).Where("username = ?", username).Count(&count).Error
+		if err != nil {
+			return models.User{}, err
+		}
+		if count == 0 {
+			break
+		}
+		username = fmt.Sprintf("%s_%d", base, n)
+	}
+
+	user := models.User{
+		Username: username,
+		Email:    identity.Email,
+		Password: password,
+	}
+	if identity.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	_, err = user.SaveUser(server.DB)
+	if err != nil {
+		return models.User{}, err
+	}
+	if !identity.EmailVerified {
+		err = server.sendVerificationEmail(&user)
+		if err != nil {
+			log.Printf("Sending verification mail failed: %v", err)
+		}
+	}
+	return user, nil
+}
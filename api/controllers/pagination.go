@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/utils/cursor"
+)
+
+// Page is one page of a listing, every listing answers with it. The cursors are passed back as ?cursor= for the
+// pages after and before this one and the links are those requests ready to follow, they are empty where the
+// listing ends
+type Page struct {
+	Data       interface{} `json:"data"`
+	Total      int         `json:"total"`
+	NextCursor string      `json:"next_cursor"`
+	PrevCursor string      `json:"prev_cursor"`
+	Links      PageLinks   `json:"links"`
+}
+
+type PageLinks struct {
+	Next string `json:"next"`
+	Prev string `json:"prev"`
+}
+
+// pageSize reads ?limit=, up to models.MaxPageLimit items per page
+func pageSize(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return models.DefaultPageLimit, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > models.MaxPageLimit {
+		return 0, errors.New("Invalid Limit")
+	}
+	return limit, nil
+}
+
+// paging reads which page of a listing is asked for: ?limit=, ?cursor= and ?sort=, where a leading - sorts
+// descending, ?sort=-created_at. The models check the sort
+func paging(r *http.Request) (models.Paging, error) {
+	var err error
+	page := models.Paging{}
+	page.Limit, err = pageSize(r)
+	if err != nil {
+		return models.Paging{}, err
+	}
+	page.Cursor, err = cursor.Decode(r.URL.Query().Get("cursor"))
+	if err != nil {
+		return models.Paging{}, err
+	}
+	sort := r.URL.Query().Get("sort")
+	page.Desc = strings.HasPrefix(sort, "-")
+	page.Sort = strings.TrimPrefix(sort, "-")
+	return page, nil
+}
+
+// timeParam reads a time from the query, either a date, 2006-01-02, or a full RFC 3339 time
+func timeParam(r *http.Request, name string) (time.Time, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err == nil {
+		return t, nil
+	}
+	t, err = time.Parse("2006-01-02", raw)
+	if err != nil {
+		return time.Time{}, errors.New("Invalid " + name)
+	}
+	return t, nil
+}
+
+// newPage puts a page of a listing into the envelope, the links repeat the request with the cursors swapped in
+func newPage(r *http.Request, data interface{}, info models.PageInfo) Page {
+	page := Page{Data: data, Total: info.Total}
+	link := func(c *cursor.Cursor) (string, string) {
+		if c == nil {
+			return "", ""
+		}
+		token := c.Encode()
+		query := r.URL.Query()
+		query.Set("cursor", token)
+		// The cursor brings its sort along
+		query.Del("sort")
+		return token, r.URL.Path + "?" + query.Encode()
+	}
+	page.NextCursor, page.Links.Next = link(info.Next)
+	page.PrevCursor, page.Links.Prev = link(info.Prev)
+	return page
+}
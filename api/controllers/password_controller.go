@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/badoux/checkmail"
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/mailer"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/responses"
+)
+
+const passwordResetLifetime = time.Hour * 1
+
+func (server *Server) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	request := struct {
+		Email string `json:"email"`
+	}{}
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	request.Email = strings.TrimSpace(request.Email)
+	if request.Email == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Email Required"))
+		return
+	}
+	if err = checkmail.ValidateFormat(request.Email); err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Invalid Email"))
+		return
+	}
+
+	// The response is the same whether or not the account exists so it can't be used to look up emails
+	accepted := "If the email belongs to an account, a reset link has been sent to it"
+
+	user := models.User{}
+	err = server.DB.Debug().Model(models.User{}).Where("email = ?", request.Email).Take(&user).Error
+	if err != nil {
+		responses.JSON(w, http.StatusAccepted, accepted)
+		return
+	}
+	token, err := auth.GenerateOpaqueToken(32)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	reset := models.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(passwordResetLifetime),
+	}
+	_, err = reset.SavePasswordReset(server.DB)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	what, line := server.passwordResetLink(token)
+	err = server.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the %s below to choose a new password. It expires in %.0f minutes.\n\n%s\n\nIf you did not ask for a reset you can ignore this email.",
+			user.Username, what, passwordResetLifetime.Minutes(), line),
+	})
+	if err != nil {
+		log.Printf("Sending password reset mail failed: %v", err)
+	}
+	responses.JSON(w, http.StatusAccepted, accepted)
+}
+
+// passwordResetLink is what a reset mail hands out, a link to the frontend's page for choosing a new password when
+// there is one. The API only takes the token in a POST, so without a page the mail carries the bare token
+func (server *Server) passwordResetLink(token string) (what, line string) {
+	link, err := url.Parse(server.PasswordResetURL)
+	if server.PasswordResetURL == "" || err != nil {
+		return "token", "token=" + token
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return "link", link.String()
+}
+
+func (server *Server) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	request := struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}{}
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if request.Token == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Token Required"))
+		return
+	}
+	if request.Password == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Password Required"))
+		return
+	}
+
+	reset := models.PasswordReset{}
+	_, err = reset.FindPasswordReset(server.DB, auth.HashToken(request.Token))
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Invalid or Expired Token"))
+		return
+	}
+	err = reset.UsePasswordReset(server.DB)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	user := models.User{}
+	err = user.UpdatePassword(server.DB, reset.UserID, request.Password)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Whoever knew the old password should not stay logged in
+	refreshToken := models.RefreshToken{}
+	_, err = refreshToken.RevokeUserTokens(server.DB, reset.UserID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, "Password has been reset")
+}
@@ -5,13 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
 	"github.com/mmosoroohh/Go_Medium_API/api/auth"
 	"github.com/mmosoroohh/Go_Medium_API/api/models"
 	"github.com/mmosoroohh/Go_Medium_API/api/responses"
 	"github.com/mmosoroohh/Go_Medium_API/api/utils/formaterror"
 	"io/ioutil"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 )
 
 func (server *Server) CreatePost(w http.ResponseWriter, r *http.Request) {
@@ -32,33 +36,115 @@ func (server *Server) CreatePost(w http.ResponseWriter, r *http.Request) {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
 		return
 	}
-	uid, err := auth.ExtractTokenID(r)
+	principal, err := auth.PrincipalFromRequest(r)
 	if err != nil {
 		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
 		return
 	}
+	uid := principal.UserID
 	if uid != post.AuthorID {
 		responses.ERROR(w, http.StatusUnauthorized, errors.New(http.StatusText(http.StatusUnauthorized)))
 		return
 	}
+	if server.RequireVerifiedEmail {
+		author := models.User{}
+		err = server.DB.Debug().Model(models.User{}).Where("id = ?", uid).Take(&author).Error
+		if err != nil || !author.EmailVerified() {
+			responses.ERROR(w, http.StatusForbidden, errors.New("Email Not Verified"))
+			return
+		}
+	}
 	postCreated, err := post.SavePost(server.DB)
 	if err != nil {
 		formattedError := formaterror.FormatError(err.Error())
 		responses.ERROR(w, http.StatusInternalServerError, formattedError)
 		return
 	}
+	if postCreated.Visible(server.now()) {
+		server.PostPublished(postCreated)
+	}
 	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.URL.Path, postCreated.ID))
 	responses.JSON(w, http.StatusCreated, postCreated)
 }
 
+// GetPosts lists published posts, ?status= lists the caller's own posts in another state
+// and ?scheduled=true their posts that are yet to go live. ?tag=, ?author_id=, ?created_after= and
+// ?created_before= narrow any of these down. It answers with a Page, see paging for sorting and paging through
 func (server *Server) GetPosts(w http.ResponseWriter, r *http.Request) {
+	filter := models.PostFilter{Status: r.URL.Query().Get("status"), Scheduled: r.URL.Query().Get("scheduled") == "true"}
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		filter.Tag = models.NormalizeTag(tag)
+	}
+	if author := r.URL.Query().Get("author_id"); author != "" {
+		aid, err := strconv.ParseUint(author, 10, 32)
+		if err != nil {
+			responses.ERROR(w, http.StatusBadRequest, errors.New("Invalid author_id"))
+			return
+		}
+		filter.AuthorID = uint32(aid)
+	}
+	var err error
+	filter.CreatedAfter, err = timeParam(r, "created_after")
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	filter.CreatedBefore, err = timeParam(r, "created_before")
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	page, err := paging(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	if filter.Status == "" {
+		filter.Status = models.PostStatusPublished
+	}
+	if !models.ValidPostStatus(filter.Status) {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("Invalid Status"))
+		return
+	}
+	if !filter.Scheduled {
+		filter.VisibleAt = server.now()
+	}
+	if filter.Status != models.PostStatusPublished || filter.Scheduled {
+		principal, err := auth.PrincipalFromRequest(r)
+		if err != nil {
+			responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+			return
+		}
+		// Editors look after everyone's posts, so they get to see all of them
+		if !principal.HasPermission(auth.PermPostsUpdateAny) {
+			if filter.AuthorID != 0 && filter.AuthorID != principal.UserID {
+				responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+				return
+			}
+			filter.AuthorID = principal.UserID
+		}
+	}
+	server.postsPage(w, r, filter, page)
+}
+
+// postsPage answers with a page of the posts that match the filter
+func (server *Server) postsPage(w http.ResponseWriter, r *http.Request, filter models.PostFilter, page models.Paging) {
 	post := models.Post{}
-	posts, err := post.AllPosts(server.DB)
+	posts, info, err := post.AllPosts(server.DB, filter, page)
 	if err != nil {
+		if err.Error() == "Invalid Sort" {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
 		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
-	responses.JSON(w, http.StatusOK, posts)
+	err = server.markPosts(r, *posts)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, newPage(r, posts, info))
 }
 
 func (server *Server) GetPost(w http.ResponseWriter, r *http.Request) {
@@ -71,6 +157,19 @@ func (server *Server) GetPost(w http.ResponseWriter, r *http.Request) {
 	post := models.Post{}
 
 	postReceived, err := post.SinglePost(server.DB, pid)
+	if gorm.IsRecordNotFoundError(err) {
+		responses.ERROR(w, http.StatusNotFound, errors.New("Post not found"))
+		return
+	}
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !server.canReadPost(r, postReceived) {
+		responses.ERROR(w, http.StatusNotFound, errors.New("Post not found"))
+		return
+	}
+	err = server.markPost(r, postReceived)
 	if err != nil {
 		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
@@ -78,6 +177,155 @@ func (server *Server) GetPost(w http.ResponseWriter, r *http.Request) {
 	responses.JSON(w, http.StatusOK, postReceived)
 }
 
+// GetPostBySlug finds a post by its slug, old slugs of renamed posts redirect permanently to the current one
+func (server *Server) GetPostBySlug(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	post := models.Post{}
+	postReceived, err := post.SinglePostBySlug(server.DB, slug)
+	if err == nil {
+		if !server.canReadPost(r, postReceived) {
+			responses.ERROR(w, http.StatusNotFound, errors.New("Post not found"))
+			return
+		}
+		err = server.markPost(r, postReceived)
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+		responses.JSON(w, http.StatusOK, postReceived)
+		return
+	}
+	if !gorm.IsRecordNotFoundError(err) {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	oldSlug := models.PostSlug{}
+	pid, err := oldSlug.FindPostIDByOldSlug(server.DB, slug)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, errors.New("Post not found"))
+		return
+	}
+	current := models.Post{}
+	err = server.DB.Debug().Model(models.Post{}).Where("id = ?", pid).Take(&current).Error
+	if err != nil || !server.canReadPost(r, &current) {
+		responses.ERROR(w, http.StatusNotFound, errors.New("Post not found"))
+		return
+	}
+	http.Redirect(w, r, "/posts/by-slug/"+url.PathEscape(current.Slug), http.StatusMovedPermanently)
+}
+
+// readablePost loads the post of the request for claps, comments and bookmarks, which only go to posts the user
+// can read
+func (server *Server) readablePost(w http.ResponseWriter, r *http.Request) (*models.Post, bool) {
+	vars := mux.Vars(r)
+	pid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	return server.findReadablePost(w, r, pid)
+}
+
+func (server *Server) findReadablePost(w http.ResponseWriter, r *http.Request, pid uint64) (*models.Post, bool) {
+	post := models.Post{}
+	err := server.DB.Debug().Model(models.Post{}).Where("id = ?", pid).Take(&post).Error
+	if err != nil || !server.canReadPost(r, &post) {
+		responses.ERROR(w, http.StatusNotFound, errors.New("Post not found"))
+		return nil, false
+	}
+	return &post, true
+}
+
+// canReadPost hides drafts, archived posts and posts that are not due yet from everyone but their author and editors
+func (server *Server) canReadPost(r *http.Request, post *models.Post) bool {
+	if post.Visible(server.now()) {
+		return true
+	}
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		return false
+	}
+	return principal.CanActOn(post.AuthorID, auth.PermPostsUpdateAny)
+}
+
+// PublishPost makes the post public, {"status": "unlisted"} publishes it without listing it
+// and {"publish_at": "2006-01-02T15:04:05Z"} schedules it to go live later
+func (server *Server) PublishPost(w http.ResponseWriter, r *http.Request) {
+	server.changePostStatus(w, r, models.PostStatusPublished, models.PostStatusUnlisted)
+}
+
+// UnpublishPost takes the post back to a draft, {"status": "archived"} archives it instead
+func (server *Server) UnpublishPost(w http.ResponseWriter, r *http.Request) {
+	server.changePostStatus(w, r, models.PostStatusDraft, models.PostStatusArchived)
+}
+
+// changePostStatus moves the post to the status given in the body, which must be one of the allowed ones,
+// defaulting to the first
+func (server *Server) changePostStatus(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	vars := mux.Vars(r)
+	pid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	request := struct {
+		Status    string     `json:"status"`
+		PublishAt *time.Time `json:"publish_at"`
+	}{}
+	if len(body) > 0 {
+		err = json.Unmarshal(body, &request)
+		if err != nil {
+			responses.ERROR(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+	}
+	status := allowed[0]
+	if request.Status != "" {
+		status = ""
+		for _, s := range allowed {
+			if request.Status == s {
+				status = s
+			}
+		}
+		if status == "" {
+			responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Invalid Status"))
+			return
+		}
+	}
+
+	post := models.Post{}
+	err = server.DB.Debug().Model(models.Post{}).Where("id = ?", pid).Take(&post).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, errors.New("Post not found"))
+		return
+	}
+	if !principal.CanActOn(post.AuthorID, auth.PermPostsUpdateAny) {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	wasVisible := post.Visible(server.now())
+	postUpdated, err := post.UpdateStatus(server.DB, status, request.PublishAt, server.now())
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !wasVisible && postUpdated.Visible(server.now()) {
+		server.PostPublished(postUpdated)
+	}
+	responses.JSON(w, http.StatusOK, postUpdated)
+}
+
 func (server *Server) UpdatePost(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 
@@ -88,8 +336,8 @@ func (server *Server) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if the auth token is valid and get the user id from it
-	uid, err := auth.ExtractTokenID(r)
+	// Check if the auth token is valid
+	principal, err := auth.PrincipalFromRequest(r)
 	if err != nil {
 		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
 		return
@@ -103,8 +351,8 @@ func (server *Server) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// If a user attempts to update a post that doesn't belonging to him/her
-	if uid != post.AuthorID {
+	// If a user attempts to update a post that doesn't belonging to him/her, unless they are an editor
+	if !principal.CanActOn(post.AuthorID, auth.PermPostsUpdateAny) {
 		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
 		return
 	}
@@ -124,8 +372,8 @@ func (server *Server) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if the request user id is equal to the one from the token
-	if uid != postUpdate.AuthorID {
+	// The author of a post can not be changed
+	if post.AuthorID != postUpdate.AuthorID {
 		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unathorized"))
 		return
 	}
@@ -137,8 +385,12 @@ func (server *Server) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// It is important to tell the model the post id to update, the other update field are set above
+	// It is important to tell the model the post id to update, the other update field are set above.
+	// The status only changes through publish and unpublish
 	postUpdate.ID = post.ID
+	postUpdate.Status, postUpdate.PublishedAt, postUpdate.PublishAt, postUpdate.CreatedAt = post.Status, post.PublishedAt, post.PublishAt, post.CreatedAt
+	postUpdate.ClapCount = post.ClapCount
+	postUpdate.EditorID = principal.UserID
 	postUpdated, err := postUpdate.UpdatePost(server.DB)
 
 	if err != nil {
@@ -146,6 +398,7 @@ func (server *Server) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		responses.ERROR(w, http.StatusInternalServerError, formattedError)
 		return
 	}
+	server.pruneRevisions(postUpdated.ID)
 	responses.JSON(w, http.StatusOK, postUpdated)
 }
 
@@ -160,7 +413,7 @@ func (server *Server) DeletePost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Is this user authenticated
-	uid, err := auth.ExtractTokenID(r)
+	principal, err := auth.PrincipalFromRequest(r)
 	if err != nil {
 		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
 		return
@@ -174,12 +427,12 @@ func (server *Server) DeletePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Is the authenticated user, owner of the post
-	if uid != post.AuthorID {
+	// Is the authenticated user, owner of the post or an editor
+	if !principal.CanActOn(post.AuthorID, auth.PermPostsDeleteAny) {
 		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
 		return
 	}
-	_, err = post.DeletePost(server.DB, pid, uid)
+	_, err = post.DeletePost(server.DB, pid, post.AuthorID)
 	if err != nil {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
@@ -187,3 +440,8 @@ func (server *Server) DeletePost(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Entity", fmt.Sprintf("%d", pid))
 	responses.JSON(w, http.StatusNoContent, "")
 }
+
+// PostPublished runs the side effects of a post going live, whether it was published right away or by the scheduler
+func (server *Server) PostPublished(post *models.Post) {
+	log.Printf("Post %d by user %d is live", post.ID, post.AuthorID)
+}
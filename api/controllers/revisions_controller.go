@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/responses"
+	"github.com/mmosoroohh/Go_Medium_API/api/utils/formaterror"
+	"github.com/mmosoroohh/Go_Medium_API/api/utils/linediff"
+)
+
+// RevisionDiff compares two revisions of a post, from the older to the newer one
+type RevisionDiff struct {
+	From    int             `json:"from"`
+	To      int             `json:"to"`
+	Title   []linediff.Line `json:"title"`
+	Content []linediff.Line `json:"content"`
+}
+
+// revisionPost loads the post of a revision request. Old versions may hold what the author took out again,
+// so only the author and editors get to see them
+func (server *Server) revisionPost(w http.ResponseWriter, r *http.Request) (*models.Post, *auth.Principal, bool) {
+	vars := mux.Vars(r)
+	pid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return nil, nil, false
+	}
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return nil, nil, false
+	}
+	post := models.Post{}
+	err = server.DB.Debug().Model(models.Post{}).Where("id = ?", pid).Take(&post).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, errors.New("Post not found"))
+		return nil, nil, false
+	}
+	if !principal.CanActOn(post.AuthorID, auth.PermPostsUpdateAny) {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return nil, nil, false
+	}
+	return &post, principal, true
+}
+
+// findRevision loads the revision named in the url
+func (server *Server) findRevision(w http.ResponseWriter, r *http.Request, pid uint64) (*models.PostRevision, bool) {
+	number, err := strconv.Atoi(mux.Vars(r)["rev"])
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	revision := models.PostRevision{}
+	_, err = revision.FindRevision(server.DB, pid, number)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, errors.New("Revision Not Found"))
+		return nil, false
+	}
+	return &revision, true
+}
+
+// pruneRevisions applies the retention policy after a post changed, failing to prune does not fail the change
+func (server *Server) pruneRevisions(pid uint64) {
+	err := models.PruneRevisions(server.DB, pid, server.RevisionRetention, server.now())
+	if err != nil {
+		log.Printf("Cannot prune the revisions of post %d: %v", pid, err)
+	}
+}
+
+// GetRevisions lists the kept revisions of a post, newest first
+func (server *Server) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	post, _, ok := server.revisionPost(w, r)
+	if !ok {
+		return
+	}
+	revision := models.PostRevision{}
+	revisions, err := revision.FindRevisions(server.DB, post.ID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, revisions)
+}
+
+func (server *Server) GetRevision(w http.ResponseWriter, r *http.Request) {
+	post, _, ok := server.revisionPost(w, r)
+	if !ok {
+		return
+	}
+	revision, ok := server.findRevision(w, r, post.ID)
+	if !ok {
+		return
+	}
+	responses.JSON(w, http.StatusOK, revision)
+}
+
+// DiffRevision shows what changed up to the revision, by default since the one before it, ?against= picks another
+func (server *Server) DiffRevision(w http.ResponseWriter, r *http.Request) {
+	post, _, ok := server.revisionPost(w, r)
+	if !ok {
+		return
+	}
+	revision, ok := server.findRevision(w, r, post.ID)
+	if !ok {
+		return
+	}
+	against := &models.PostRevision{}
+	var err error
+	if value := r.URL.Query().Get("against"); value != "" {
+		number, convErr := strconv.Atoi(value)
+		if convErr != nil {
+			responses.ERROR(w, http.StatusBadRequest, convErr)
+			return
+		}
+		_, err = against.FindRevision(server.DB, post.ID, number)
+	} else {
+		against, err = revision.FindPreviousRevision(server.DB)
+	}
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, errors.New("Revision Not Found"))
+		return
+	}
+	from, to := against, revision
+	if from.Number > to.Number {
+		from, to = to, from
+	}
+	responses.JSON(w, http.StatusOK, RevisionDiff{
+		From:    from.Number,
+		To:      to.Number,
+		Title:   linediff.Diff(from.Title, to.Title),
+		Content: linediff.Diff(from.Content, to.Content),
+	})
+}
+
+// RestoreRevision makes an old revision the current version of the post, as a new revision
+func (server *Server) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	post, principal, ok := server.revisionPost(w, r)
+	if !ok {
+		return
+	}
+	revision, ok := server.findRevision(w, r, post.ID)
+	if !ok {
+		return
+	}
+	postRestored, err := post.RestoreRevision(server.DB, revision, principal.UserID)
+	if err != nil {
+		formattedError := formaterror.FormatError(err.Error())
+		responses.ERROR(w, http.StatusInternalServerError, formattedError)
+		return
+	}
+	server.pruneRevisions(postRestored.ID)
+	responses.JSON(w, http.StatusOK, postRestored)
+}
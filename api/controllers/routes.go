@@ -1,26 +1,103 @@
 package controllers
 
-import "github.com/mmosoroohh/Go_Medium_API/api/middlewares"
+import (
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/middlewares"
+)
 
 func (s *Server) initializeRoutes() {
 
 	// Home Route
 	s.Router.HandleFunc("/", middlewares.SetMiddlewareJSON(s.Home)).Methods("GET")
 
+	// Public keys for verifying our tokens
+	s.Router.HandleFunc("/.well-known/jwks.json", middlewares.SetMiddlewareJSON(s.JWKS)).Methods("GET")
+
 	// Login Route
 	s.Router.HandleFunc("/login", middlewares.SetMiddlewareJSON(s.Login)).Methods("POST")
+	s.Router.HandleFunc("/login/mfa", middlewares.SetMiddlewareJSON(s.LoginMFA)).Methods("POST")
+	s.Router.HandleFunc("/token/refresh", middlewares.SetMiddlewareJSON(s.RefreshToken)).Methods("POST")
+	s.Router.HandleFunc("/password/forgot", middlewares.SetMiddlewareJSON(s.ForgotPassword)).Methods("POST")
+	s.Router.HandleFunc("/password/reset", middlewares.SetMiddlewareJSON(s.ResetPassword)).Methods("POST")
+	s.Router.HandleFunc("/logout", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireSession(s.Logout)))).Methods("POST")
+
+	// Sign in with an identity provider
+	s.Router.HandleFunc("/auth/{provider}/login", s.OAuthLogin).Methods("GET")
+	s.Router.HandleFunc("/auth/{provider}/callback", middlewares.SetMiddlewareJSON(s.OAuthCallback)).Methods("GET")
+	s.Router.HandleFunc("/identities", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(s.GetIdentities))).Methods("GET")
+
+	// Two Factor Routes
+	s.Router.HandleFunc("/2fa/enroll", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireSession(s.EnrollTwoFactor)))).Methods("POST")
+	s.Router.HandleFunc("/2fa/confirm", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireSession(s.ConfirmTwoFactor)))).Methods("POST")
+	s.Router.HandleFunc("/2fa/recovery-codes", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireSession(s.RegenerateRecoveryCodes)))).Methods("POST")
+	s.Router.HandleFunc("/2fa/disable", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireSession(s.DisableTwoFactor)))).Methods("POST")
+
+	// API Key Routes, keys can only be managed from a login session
+	s.Router.HandleFunc("/api-keys", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireSession(s.CreateAPIKey)))).Methods("POST")
+	s.Router.HandleFunc("/api-keys", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireSession(s.GetAPIKeys)))).Methods("GET")
+	s.Router.HandleFunc("/api-keys/{id}", middlewares.SetMiddlewareAuthentication(middlewares.RequireSession(s.DeleteAPIKey))).Methods("DELETE")
 
 	// User Routes
 	s.Router.HandleFunc("/users", middlewares.SetMiddlewareJSON(s.CreateUser)).Methods("POST")
 	s.Router.HandleFunc("/users", middlewares.SetMiddlewareJSON(s.GetUsers)).Methods("GET")
+	s.Router.HandleFunc("/users/verify", middlewares.SetMiddlewareJSON(s.VerifyEmail)).Methods("GET")
+	s.Router.HandleFunc("/users/verify/resend", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireSession(s.ResendVerification)))).Methods("POST")
 	s.Router.HandleFunc("/users/{id}", middlewares.SetMiddlewareJSON(s.GetUser)).Methods("GET")
-	s.Router.HandleFunc("/users/{id}",middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(s.UpdateUser))).Methods("PUT")
-	s.Router.HandleFunc("/users/{id}", middlewares.SetMiddlewareAuthentication(s.DeleteUser)).Methods("DELETE")
+	s.Router.HandleFunc("/users/{id}", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.UpdateUser, auth.ScopeUsersWrite)))).Methods("PUT")
+	s.Router.HandleFunc("/users/{id}", middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.DeleteUser, auth.ScopeUsersWrite))).Methods("DELETE")
+	s.Router.HandleFunc("/users/{id}/restore", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.RestoreUser, auth.ScopeUsersWrite)))).Methods("POST")
+	s.Router.HandleFunc("/users/{id}/trash", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(s.GetTrash))).Methods("GET")
+	s.Router.HandleFunc("/users/{id}/follow", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.FollowUser, auth.ScopeUsersWrite)))).Methods("POST")
+	s.Router.HandleFunc("/users/{id}/follow", middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.UnfollowUser, auth.ScopeUsersWrite))).Methods("DELETE")
+	s.Router.HandleFunc("/users/{id}/followers", middlewares.SetMiddlewareJSON(s.GetFollowers)).Methods("GET")
+	s.Router.HandleFunc("/users/{id}/following", middlewares.SetMiddlewareJSON(s.GetFollowing)).Methods("GET")
+	s.Router.HandleFunc("/users/{id}/lists", middlewares.SetMiddlewareJSON(s.GetUserLists)).Methods("GET")
+	s.Router.HandleFunc("/users/{id}/role", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireSession(middlewares.RequirePermission(s.UpdateUserRole, auth.PermUsersManage))))).Methods("PUT")
+
+	// Reading List Routes, bookmarks go to the reading list every user has
+	s.Router.HandleFunc("/me/bookmarks", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(s.GetBookmarks))).Methods("GET")
+	s.Router.HandleFunc("/me/lists", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(s.GetMyLists))).Methods("GET")
+	s.Router.HandleFunc("/me/lists", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.CreateList, auth.ScopeUsersWrite)))).Methods("POST")
+	s.Router.HandleFunc("/lists/{id}", middlewares.SetMiddlewareJSON(s.GetList)).Methods("GET")
+	s.Router.HandleFunc("/lists/{id}", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.UpdateList, auth.ScopeUsersWrite)))).Methods("PUT")
+	s.Router.HandleFunc("/lists/{id}", middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.DeleteList, auth.ScopeUsersWrite))).Methods("DELETE")
+	s.Router.HandleFunc("/lists/{id}/posts", middlewares.SetMiddlewareJSON(s.GetListPosts)).Methods("GET")
+	s.Router.HandleFunc("/lists/{id}/posts", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.AddListPost, auth.ScopeUsersWrite)))).Methods("POST")
+	s.Router.HandleFunc("/lists/{id}/posts/{pid}", middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.RemoveListPost, auth.ScopeUsersWrite))).Methods("DELETE")
+
+	// Search Route, over published posts and users
+	s.Router.HandleFunc("/search", middlewares.SetMiddlewareJSON(s.Search)).Methods("GET")
 
 	// Articles Routes
-	s.Router.HandleFunc("/posts", middlewares.SetMiddlewareJSON(s.CreatePost)).Methods("POST")
+	s.Router.HandleFunc("/posts", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(middlewares.RequirePermission(s.CreatePost, auth.PermPostsCreate), auth.ScopePostsWrite)))).Methods("POST")
 	s.Router.HandleFunc("/posts", middlewares.SetMiddlewareJSON(s.GetPosts)).Methods("GET")
+	s.Router.HandleFunc("/feed", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(s.GetFeed))).Methods("GET")
+	s.Router.HandleFunc("/posts/by-slug/{slug}", middlewares.SetMiddlewareJSON(s.GetPostBySlug)).Methods("GET")
 	s.Router.HandleFunc("/posts/{id}", middlewares.SetMiddlewareJSON(s.GetPost)).Methods("GET")
-	s.Router.HandleFunc("/posts/{id}", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(s.UpdatePost))).Methods("PUT")
-	s.Router.HandleFunc("/posts/{id}", middlewares.SetMiddlewareAuthentication(s.DeletePost)).Methods("DELETE")
+	s.Router.HandleFunc("/posts/{id}", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.UpdatePost, auth.ScopePostsWrite)))).Methods("PUT")
+	s.Router.HandleFunc("/posts/{id}", middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.DeletePost, auth.ScopePostsWrite))).Methods("DELETE")
+	s.Router.HandleFunc("/posts/{id}/publish", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.PublishPost, auth.ScopePostsWrite)))).Methods("POST")
+	s.Router.HandleFunc("/posts/{id}/unpublish", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.UnpublishPost, auth.ScopePostsWrite)))).Methods("POST")
+	s.Router.HandleFunc("/posts/{id}/bookmark", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.BookmarkPost, auth.ScopeUsersWrite)))).Methods("POST")
+	s.Router.HandleFunc("/posts/{id}/bookmark", middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.UnbookmarkPost, auth.ScopeUsersWrite))).Methods("DELETE")
+	s.Router.HandleFunc("/posts/{id}/claps", middlewares.SetMiddlewareJSON(s.GetClaps)).Methods("GET")
+	s.Router.HandleFunc("/posts/{id}/claps", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.ClapPost, auth.ScopePostsWrite)))).Methods("POST")
+	s.Router.HandleFunc("/posts/{id}/claps", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.UnclapPost, auth.ScopePostsWrite)))).Methods("DELETE")
+	s.Router.HandleFunc("/posts/{id}/comments", middlewares.SetMiddlewareJSON(s.GetComments)).Methods("GET")
+	s.Router.HandleFunc("/posts/{id}/comments", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.CreateComment, auth.ScopePostsWrite)))).Methods("POST")
+	s.Router.HandleFunc("/posts/{id}/comments/{cid}", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.UpdateComment, auth.ScopePostsWrite)))).Methods("PUT")
+	s.Router.HandleFunc("/posts/{id}/comments/{cid}", middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.DeleteComment, auth.ScopePostsWrite))).Methods("DELETE")
+	s.Router.HandleFunc("/posts/{id}/comments/{cid}/hide", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.HideComment, auth.ScopePostsWrite)))).Methods("POST")
+	s.Router.HandleFunc("/posts/{id}/comments/{cid}/unhide", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.UnhideComment, auth.ScopePostsWrite)))).Methods("POST")
+	s.Router.HandleFunc("/posts/{id}/restore", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.RestorePost, auth.ScopePostsWrite)))).Methods("POST")
+	s.Router.HandleFunc("/posts/{id}/revisions", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(s.GetRevisions))).Methods("GET")
+	s.Router.HandleFunc("/posts/{id}/revisions/{rev}", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(s.GetRevision))).Methods("GET")
+	s.Router.HandleFunc("/posts/{id}/revisions/{rev}/diff", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(s.DiffRevision))).Methods("GET")
+	s.Router.HandleFunc("/posts/{id}/revisions/{rev}/restore", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireScope(s.RestoreRevision, auth.ScopePostsWrite)))).Methods("POST")
+
+	// Tag Routes, renaming and merging tags is for admins
+	s.Router.HandleFunc("/tags", middlewares.SetMiddlewareJSON(s.GetTags)).Methods("GET")
+	s.Router.HandleFunc("/tags/{tag}/posts", middlewares.SetMiddlewareJSON(s.GetTagPosts)).Methods("GET")
+	s.Router.HandleFunc("/tags/{tag}", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireSession(middlewares.RequirePermission(s.RenameTag, auth.PermTagsManage))))).Methods("PUT")
+	s.Router.HandleFunc("/tags/{tag}/merge", middlewares.SetMiddlewareJSON(middlewares.SetMiddlewareAuthentication(middlewares.RequireSession(middlewares.RequirePermission(s.MergeTag, auth.PermTagsManage))))).Methods("POST")
 }
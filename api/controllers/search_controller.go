@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/responses"
+)
+
+// searchTypes maps ?type= to the kinds of hits it asks for
+var searchTypes = map[string][]string{
+	"":      nil,
+	"posts": {models.SearchKindPost},
+	"users": {models.SearchKindUser},
+}
+
+// Search finds published posts by their title and content and users by their name, ?q=go+modules. ?type=posts or
+// ?type=users narrows the hits down, they come best first a page at a time like the other listings
+func (server *Server) Search(w http.ResponseWriter, r *http.Request) {
+	query := models.SearchQuery{Text: strings.TrimSpace(r.URL.Query().Get("q")), Now: server.now()}
+	if query.Text == "" {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("Query Required"))
+		return
+	}
+	kinds, ok := searchTypes[r.URL.Query().Get("type")]
+	if !ok {
+		responses.ERROR(w, http.StatusBadRequest, errors.New("Invalid Type"))
+		return
+	}
+	query.Kinds = kinds
+	page, err := paging(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	hits, info, err := models.Search(server.DB, server.searchEngine(), query, page)
+	if err != nil {
+		if err.Error() == "Invalid Sort" {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Posts found are marked for the user asking like in every other listing
+	posts := []models.Post{}
+	for _, hit := range *hits {
+		if hit.Post != nil {
+			posts = append(posts, *hit.Post)
+		}
+	}
+	err = server.markPosts(r, posts)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	for i := range *hits {
+		if post := (*hits)[i].Post; post != nil {
+			post.Clapped, post.Bookmarked = posts[0].Clapped, posts[0].Bookmarked
+			posts = posts[1:]
+		}
+	}
+	responses.JSON(w, http.StatusOK, newPage(r, hits, info))
+}
+
+// searchEngine is the engine set up by Initialize, or the one the database calls for
+func (server *Server) searchEngine() models.SearchEngine {
+	if server.SearchEngine == nil {
+		server.SearchEngine = models.NewSearchEngine(server.DB)
+	}
+	return server.SearchEngine
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/responses"
+)
+
+// GetTags lists the tags with how many published posts carry them
+func (server *Server) GetTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := models.TagCounts(server.DB, server.now())
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, tags)
+}
+
+// GetTagPosts lists the published posts with the tag, a page at a time like GetPosts
+func (server *Server) GetTagPosts(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	filter := models.PostFilter{Status: models.PostStatusPublished, VisibleAt: server.now(), Tag: models.NormalizeTag(vars["tag"])}
+	page, err := paging(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	server.postsPage(w, r, filter, page)
+}
+
+// RenameTag gives a tag a new name, all its posts move along with it
+func (server *Server) RenameTag(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	request := struct {
+		Name string `json:"name"`
+	}{}
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	tag := models.Tag{}
+	_, err = tag.FindTagByName(server.DB, models.NormalizeTag(vars["tag"]))
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, errors.New("Tag Not Found"))
+		return
+	}
+	renamed, err := tag.RenameTag(server.DB, request.Name)
+	if err != nil {
+		switch err.Error() {
+		case "Name Required":
+			responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		case "Tag Already Exists":
+			responses.ERROR(w, http.StatusConflict, err)
+		default:
+			responses.ERROR(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+	responses.JSON(w, http.StatusOK, renamed)
+}
+
+// MergeTag folds a tag into another one, {"into": "..."}, and deletes it
+func (server *Server) MergeTag(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	request := struct {
+		Into string `json:"into"`
+	}{}
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	tag := models.Tag{}
+	_, err = tag.FindTagByName(server.DB, models.NormalizeTag(vars["tag"]))
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, errors.New("Tag Not Found"))
+		return
+	}
+	target := models.Tag{}
+	_, err = target.FindTagByName(server.DB, models.NormalizeTag(request.Into))
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Target Tag Not Found"))
+		return
+	}
+	err = tag.MergeTag(server.DB, &target)
+	if err != nil {
+		if err.Error() == "Cannot Merge A Tag Into Itself" {
+			responses.ERROR(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, target)
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/responses"
+)
+
+// CreateTokenPair issues an access token and a refresh token for the user.
+// An empty familyID starts a new token family, i.e a new login session
+func (server *Server) CreateTokenPair(uid uint32, familyID string) (*auth.TokenDetails, error) {
+	var err error
+	if familyID == "" {
+		familyID, err = auth.GenerateOpaqueToken(24)
+		if err != nil {
+			return &auth.TokenDetails{}, err
+		}
+	}
+	// The role is read on every issue so that role changes reach the user on their next refresh
+	user := models.User{}
+	err = server.DB.Debug().Model(models.User{}).Where("id = ?", uid).Take(&user).Error
+	if err != nil {
+		return &auth.TokenDetails{}, err
+	}
+	accessToken, err := auth.CreateToken(user.ID, user.Role)
+	if err != nil {
+		return &auth.TokenDetails{}, err
+	}
+	refreshToken, err := auth.GenerateOpaqueToken(32)
+	if err != nil {
+		return &auth.TokenDetails{}, err
+	}
+	stored := models.RefreshToken{
+		UserID:    uid,
+		TokenHash: auth.HashToken(refreshToken),
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(auth.RefreshTokenLifetime),
+	}
+	_, err = stored.SaveRefreshToken(server.DB)
+	if err != nil {
+		return &auth.TokenDetails{}, err
+	}
+	return &auth.TokenDetails{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(auth.AccessTokenLifetime.Seconds()),
+	}, nil
+}
+
+func (server *Server) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	request := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{}
+	if len(body) > 0 {
+		err = json.Unmarshal(body, &request)
+		if err != nil {
+			responses.ERROR(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+	}
+	// Cookie sessions refresh with the refresh cookie, which a forged cross-site request would carry as well
+	session := false
+	if request.RefreshToken == "" {
+		request.RefreshToken = auth.ExtractSessionCookie(r, auth.RefreshCookieName)
+		session = request.RefreshToken != ""
+		if session && !auth.ValidCSRF(r) {
+			responses.ERROR(w, http.StatusForbidden, errors.New("Invalid CSRF Token"))
+			return
+		}
+	}
+	if request.RefreshToken == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Refresh Token Required"))
+		return
+	}
+
+	// Check if the refresh token was issued by us
+	refreshToken := models.RefreshToken{}
+	_, err = refreshToken.FindRefreshToken(server.DB, auth.HashToken(request.RefreshToken))
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+
+	// A token that has already been rotated is being replayed, assume it was stolen and end the whole session
+	if refreshToken.Revoked() {
+		_, _ = refreshToken.RevokeTokenFamily(server.DB, refreshToken.FamilyID)
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	if refreshToken.Expired() {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+
+	// Rotate the token, losing the race against a concurrent refresh is treated as reuse as well
+	revoked, err := refreshToken.RevokeRefreshToken(server.DB)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	if revoked != 1 {
+		_, _ = refreshToken.RevokeTokenFamily(server.DB, refreshToken.FamilyID)
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+
+	tokens, err := server.CreateTokenPair(refreshToken.UserID, refreshToken.FamilyID)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	server.writeTokens(w, tokens, session)
+}
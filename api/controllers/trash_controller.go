@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/responses"
+)
+
+// restorable reports whether something deleted at the given time is still in the trash, and not about to be purged
+func (server *Server) restorable(deletedAt *time.Time) bool {
+	return deletedAt != nil && (server.TrashRetention <= 0 || server.now().Sub(*deletedAt) < server.TrashRetention)
+}
+
+// GetTrash lists the posts of a user that are in the trash
+func (server *Server) GetTrash(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	uid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	if !principal.CanActOn(uint32(uid), auth.PermPostsDeleteAny) {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	post := models.Post{}
+	posts, err := post.TrashedPosts(server.DB, uint32(uid))
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, posts)
+}
+
+// RestorePost takes a post out of the trash, for its author or an editor
+func (server *Server) RestorePost(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	pid, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	post := models.Post{}
+	_, err = post.FindTrashedPost(server.DB, pid)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, errors.New("Post not found"))
+		return
+	}
+	if !principal.CanActOn(post.AuthorID, auth.PermPostsDeleteAny) {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	if !server.restorable(post.DeletedAt) {
+		responses.ERROR(w, http.StatusGone, errors.New("Restore Period Expired"))
+		return
+	}
+	postRestored, err := post.RestorePost(server.DB)
+	if err != nil {
+		if err.Error() == "Author Not Found" {
+			responses.ERROR(w, http.StatusConflict, err)
+			return
+		}
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, postRestored)
+}
+
+// RestoreUser takes an account out of the trash, for an admin only. A deleted account's tokens no longer work, the
+// user has to ask an admin to restore it and then sign in again
+func (server *Server) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	uid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	if !principal.HasPermission(auth.PermUsersDeleteAny) {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	user := models.User{}
+	_, err = user.FindTrashedUser(server.DB, uint32(uid))
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, errors.New("User Not Found"))
+		return
+	}
+	if !server.restorable(user.DeletedAt) {
+		responses.ERROR(w, http.StatusGone, errors.New("Restore Period Expired"))
+		return
+	}
+	userRestored, err := user.RestoreUser(server.DB)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, userRestored)
+}
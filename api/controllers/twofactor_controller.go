@@ -0,0 +1,326 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/responses"
+)
+
+const recoveryCodeCount = 10
+
+type twoFactorRequest struct {
+	Code     string `json:"code"`
+	Password string `json:"password"`
+	MFAToken string `json:"mfa_token"`
+	Session  bool   `json:"session"`
+}
+
+func readTwoFactorRequest(r *http.Request) (twoFactorRequest, error) {
+	request := twoFactorRequest{}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return request, err
+	}
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		return request, err
+	}
+	if strings.TrimSpace(request.Code) == "" {
+		return request, errors.New("Code Required")
+	}
+	return request, nil
+}
+
+// normalizeRecoveryCode lets users type recovery codes with or without the dash and in any case
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.Replace(code, "-", "", -1)
+}
+
+// newRecoveryCodes generates a fresh set of recovery codes, storing only their hashes
+func (server *Server) newRecoveryCodes(uid uint32) ([]string, error) {
+	codes := []string{}
+	hashes := []string{}
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		code := hex.EncodeToString(b)
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, auth.HashToken(code))
+	}
+	recoveryCode := models.RecoveryCode{}
+	err := recoveryCode.ReplaceRecoveryCodes(server.DB, uid, hashes)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// verifySecondFactor accepts either a current TOTP code or one of the unused recovery codes
+func (server *Server) verifySecondFactor(twoFactor *models.TwoFactor, code string) error {
+	step, ok := auth.ValidateTOTP(twoFactor.Secret, code, time.Now())
+	if ok {
+		return twoFactor.UseStep(server.DB, step)
+	}
+	recoveryCode := models.RecoveryCode{}
+	err := recoveryCode.UseRecoveryCode(server.DB, twoFactor.UserID, auth.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return errors.New("Invalid Code")
+	}
+	return nil
+}
+
+func (server *Server) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	uid := principal.UserID
+	user := models.User{}
+	err = server.DB.Debug().Model(models.User{}).Where("id = ?", uid).Take(&user).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, errors.New("User Not Found"))
+		return
+	}
+	twoFactor := models.TwoFactor{}
+	enabled, err := twoFactor.TwoFactorEnabled(server.DB, uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	if enabled {
+		responses.ERROR(w, http.StatusConflict, errors.New("Two Factor Already Enabled"))
+		return
+	}
+	secret, uri, err := auth.GenerateTOTPSecret(user.Email)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	twoFactor.UserID = uid
+	twoFactor.Secret = secret
+	_, err = twoFactor.SaveTwoFactor(server.DB)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusCreated, struct {
+		Secret          string `json:"secret"`
+		ProvisioningURI string `json:"provisioning_uri"`
+	}{
+		Secret:          secret,
+		ProvisioningURI: uri,
+	})
+}
+
+func (server *Server) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	uid := principal.UserID
+	request, err := readTwoFactorRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	twoFactor := models.TwoFactor{}
+	_, err = twoFactor.FindTwoFactor(server.DB, uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, err)
+		return
+	}
+	if twoFactor.Enabled() {
+		responses.ERROR(w, http.StatusConflict, errors.New("Two Factor Already Enabled"))
+		return
+	}
+
+	// Only a TOTP code proves the authenticator app was set up, there are no recovery codes yet anyway
+	step, ok := auth.ValidateTOTP(twoFactor.Secret, request.Code, time.Now())
+	if !ok {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Invalid Code"))
+		return
+	}
+	err = twoFactor.UseStep(server.DB, step)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	err = twoFactor.ConfirmTwoFactor(server.DB)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	codes, err := server.newRecoveryCodes(uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: codes,
+	})
+}
+
+func (server *Server) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	uid := principal.UserID
+	request, err := readTwoFactorRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	twoFactor := models.TwoFactor{}
+	_, err = twoFactor.FindTwoFactor(server.DB, uid)
+	if err != nil || !twoFactor.Enabled() {
+		responses.ERROR(w, http.StatusNotFound, errors.New("Two Factor Not Enabled"))
+		return
+	}
+	step, ok := auth.ValidateTOTP(twoFactor.Secret, request.Code, time.Now())
+	if !ok {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Invalid Code"))
+		return
+	}
+	err = twoFactor.UseStep(server.DB, step)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	codes, err := server.newRecoveryCodes(uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: codes,
+	})
+}
+
+// DisableTwoFactor asks for the password and a second factor again, a stolen access token alone is not enough
+func (server *Server) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	uid := principal.UserID
+	request, err := readTwoFactorRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if request.Password == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Password Required"))
+		return
+	}
+	user := models.User{}
+	err = server.DB.Debug().Model(models.User{}).Where("id = ?", uid).Take(&user).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, errors.New("User Not Found"))
+		return
+	}
+
+	// Shares its attempts with LoginMFA, so a stolen session cannot guess codes here instead
+	attemptKey := fmt.Sprintf("mfa:%d", uid)
+	now := time.Now()
+	if wait := server.LoginAttempts.Reserve(attemptKey, now); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		responses.ERROR(w, http.StatusTooManyRequests, errors.New("Too Many Login Attempts"))
+		return
+	}
+	err = models.VerifyPassword(user.Password, request.Password)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Password is Incorrect"))
+		return
+	}
+	twoFactor := models.TwoFactor{}
+	_, err = twoFactor.FindTwoFactor(server.DB, uid)
+	if err != nil || !twoFactor.Enabled() {
+		server.LoginAttempts.Release(attemptKey)
+		responses.ERROR(w, http.StatusNotFound, errors.New("Two Factor Not Enabled"))
+		return
+	}
+	err = server.verifySecondFactor(&twoFactor, request.Code)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+	server.LoginAttempts.Reset(attemptKey)
+	err = twoFactor.DeleteTwoFactor(server.DB, uid)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusNoContent, "")
+}
+
+// LoginMFA exchanges the mfa pending token from Login and a valid code for a token pair
+func (server *Server) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	request, err := readTwoFactorRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	mfaToken, err := auth.ParseMFAToken(request.MFAToken)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	uid := mfaToken.UserID
+	twoFactor := models.TwoFactor{}
+	_, err = twoFactor.FindTwoFactor(server.DB, uid)
+	if err != nil || !twoFactor.Enabled() {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+
+	// Six digit codes are easy to guess without a limit on attempts
+	attemptKey := fmt.Sprintf("mfa:%d", uid)
+	now := time.Now()
+	if wait := server.LoginAttempts.Reserve(attemptKey, now); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		responses.ERROR(w, http.StatusTooManyRequests, errors.New("Too Many Login Attempts"))
+		return
+	}
+	err = server.verifySecondFactor(&twoFactor, request.Code)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, err)
+		return
+	}
+	server.LoginAttempts.Reset(attemptKey)
+	err = mfaToken.Use()
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	tokens, err := server.CreateTokenPair(uid, "")
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	server.writeTokens(w, tokens, request.Session)
+}
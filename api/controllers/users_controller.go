@@ -10,6 +10,7 @@ import (
 	"github.com/mmosoroohh/Go_Medium_API/api/responses"
 	"github.com/mmosoroohh/Go_Medium_API/api/utils/formaterror"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"strconv"
 )
@@ -38,19 +39,46 @@ func (server *Server) CreateUser(w http.ResponseWriter, r *http.Request) {
 		responses.ERROR(w, http.StatusInternalServerError, formattedError)
 		return
 	}
+	err = server.sendVerificationEmail(userCreated)
+	if err != nil {
+		log.Printf("Sending verification mail failed: %v", err)
+	}
 	w.Header().Set("Location", fmt.Sprintf("%s%s/%d", r.Host, r.RequestURI, userCreated.ID))
 	responses.JSON(w, http.StatusCreated, userCreated)
 }
 
+// GetUsers lists the users a page at a time, see paging. ?role=, ?created_after= and ?created_before= narrow
+// the listing down
 func (server *Server) GetUsers(w http.ResponseWriter, r *http.Request) {
+	var err error
+	filter := models.UserFilter{Role: r.URL.Query().Get("role")}
+	filter.CreatedAfter, err = timeParam(r, "created_after")
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	filter.CreatedBefore, err = timeParam(r, "created_before")
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	page, err := paging(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
 	user := models.User{}
 
-	users, err := user.AllUsers(server.DB)
+	users, info, err := user.AllUsers(server.DB, filter, page)
 	if err != nil {
+		if err.Error() == "Invalid Sort" {
+			responses.ERROR(w, http.StatusBadRequest, err)
+			return
+		}
 		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
-	responses.JSON(w, http.StatusOK, users)
+	responses.JSON(w, http.StatusOK, newPage(r, users, info))
 }
 
 func (server *Server) GetUser(w http.ResponseWriter, r *http.Request) {
@@ -87,12 +115,12 @@ func (server *Server) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
 		return
 	}
-	tokenID, err := auth.ExtractTokenID(r)
+	principal, err := auth.PrincipalFromRequest(r)
 	if err != nil {
 		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
 		return
 	}
-	if tokenID != uint32(uid) {
+	if !principal.CanActOn(uint32(uid), auth.PermUsersUpdateAny) {
 		responses.ERROR(w, http.StatusUnauthorized, errors.New(http.StatusText(http.StatusUnauthorized)))
 		return
 	}
@@ -102,12 +130,34 @@ func (server *Server) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		responses.ERROR(w, http.StatusUnprocessableEntity, err)
 		return
 	}
+	currentUser := models.User{}
+	err = server.DB.Debug().Model(models.User{}).Where("id = ?", uid).Take(&currentUser).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, errors.New("User Not Found"))
+		return
+	}
+	// Changing the address sends a verification mail, so it is rate limited like resending one
+	if user.Email != currentUser.Email && !server.allowVerificationEmail(w, uint32(uid)) {
+		return
+	}
 	updatedUser, err := user.UpdateAUser(server.DB, uint32(uid))
 	if err != nil {
 		formattedError := formaterror.FormatError(err.Error())
 		responses.ERROR(w, http.StatusInternalServerError, formattedError)
 		return
 	}
+	// A new email address has to be verified again
+	if updatedUser.Email != currentUser.Email {
+		updatedUser, err = updatedUser.ClearEmailVerification(server.DB)
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+		err = server.sendVerificationEmail(updatedUser)
+		if err != nil {
+			log.Printf("Sending verification mail failed: %v", err)
+		}
+	}
 	responses.JSON(w, http.StatusOK, updatedUser)
 }
 
@@ -120,12 +170,12 @@ func (server *Server) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		responses.ERROR(w, http.StatusBadRequest, err)
 		return
 	}
-	tokenID, err := auth.ExtractTokenID(r)
+	principal, err := auth.PrincipalFromRequest(r)
 	if err != nil {
 		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
 		return
 	}
-	if tokenID != 0 && tokenID != uint32(uid) {
+	if !principal.CanActOn(uint32(uid), auth.PermUsersDeleteAny) {
 		responses.ERROR(w, http.StatusUnauthorized, errors.New(http.StatusText(http.StatusUnauthorized)))
 		return
 	}
@@ -134,6 +184,54 @@ func (server *Server) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		responses.ERROR(w, http.StatusInternalServerError, err)
 		return
 	}
+
+	// The account's sessions end with it, its access tokens are also turned away as long as it is in the trash
+	refreshToken := models.RefreshToken{}
+	_, err = refreshToken.RevokeUserTokens(server.DB, uint32(uid))
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	if principal.UserID == uint32(uid) && principal.AuthMethod == auth.AuthMethodJWT {
+		err = auth.RevokeToken(principal)
+		if err != nil {
+			responses.ERROR(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
 	w.Header().Set("Entity", fmt.Sprintf("%d", uid))
 	responses.JSON(w, http.StatusNoContent, "")
 }
+
+func (server *Server) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	uid, err := strconv.ParseUint(vars["id"], 10, 32)
+	if err != nil {
+		responses.ERROR(w, http.StatusBadRequest, err)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	request := struct {
+		Role string `json:"role"`
+	}{}
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if !auth.ValidRole(request.Role) {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Invalid Role"))
+		return
+	}
+	user := models.User{}
+	updatedUser, err := user.UpdateRole(server.DB, uint32(uid), request.Role)
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, errors.New("User Not Found"))
+		return
+	}
+	responses.JSON(w, http.StatusOK, updatedUser)
+}
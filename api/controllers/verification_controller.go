@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/mailer"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/responses"
+)
+
+const (
+	emailVerificationLifetime = time.Hour * 48
+	verificationResendDelay   = time.Minute * 1 // Minimum wait between two verification mails
+	verificationDailyLimit    = 5               // Maximum verification mails per user per 24 hours
+)
+
+func (server *Server) sendVerificationEmail(user *models.User) error {
+	token, err := auth.GenerateOpaqueToken(32)
+	if err != nil {
+		return err
+	}
+	verification := models.EmailVerification{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: time.Now().Add(emailVerificationLifetime),
+	}
+	_, err = verification.SaveEmailVerification(server.DB)
+	if err != nil {
+		return err
+	}
+	return server.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below.\n\n%s/users/verify?token=%s",
+			user.Username, os.Getenv("APP_URL"), token),
+	})
+}
+
+func (server *Server) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Token Required"))
+		return
+	}
+	verification := models.EmailVerification{}
+	_, err := verification.FindEmailVerification(server.DB, auth.HashToken(token))
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, errors.New("Invalid or Expired Token"))
+		return
+	}
+	err = verification.UseEmailVerification(server.DB)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	responses.JSON(w, http.StatusOK, "Email has been verified")
+}
+
+func (server *Server) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	principal, err := auth.PrincipalFromRequest(r)
+	if err != nil {
+		responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+		return
+	}
+	uid := principal.UserID
+	user := models.User{}
+	err = server.DB.Debug().Model(models.User{}).Where("id = ?", uid).Take(&user).Error
+	if err != nil {
+		responses.ERROR(w, http.StatusNotFound, errors.New("User Not Found"))
+		return
+	}
+	if user.EmailVerified() {
+		responses.ERROR(w, http.StatusConflict, errors.New("Email Already Verified"))
+		return
+	}
+
+	if !server.allowVerificationEmail(w, uid) {
+		return
+	}
+
+	err = server.sendVerificationEmail(&user)
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	responses.JSON(w, http.StatusAccepted, "Verification email sent")
+}
+
+// allowVerificationEmail rate limits verification mails on what has already been sent, this holds across every
+// instance of the API. It answers with 429 when the user has to wait
+func (server *Server) allowVerificationEmail(w http.ResponseWriter, uid uint32) bool {
+	verification := models.EmailVerification{}
+	recent, err := verification.RecentEmailVerifications(server.DB, uid, time.Now().Add(-time.Hour*24))
+	if err != nil {
+		responses.ERROR(w, http.StatusInternalServerError, err)
+		return false
+	}
+	var retryAfter time.Duration
+	if len(*recent) >= verificationDailyLimit {
+		retryAfter = time.Until((*recent)[verificationDailyLimit-1].CreatedAt.Add(time.Hour * 24))
+	} else if len(*recent) > 0 {
+		retryAfter = time.Until((*recent)[0].CreatedAt.Add(verificationResendDelay))
+	}
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+		responses.ERROR(w, http.StatusTooManyRequests, errors.New("Too Many Requests"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+// Package markdown turns the Markdown posts are written in into HTML that is safe to show to readers
+package markdown
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// DefaultEmbedHosts are the video players posts may embed with an iframe
+var DefaultEmbedHosts = []string{"www.youtube.com", "www.youtube-nocookie.com", "player.vimeo.com"}
+
+// Raw HTML is let through the renderer on purpose, the sanitizer decides what stays
+var renderer = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+var policy = newPolicy(DefaultEmbedHosts)
+
+// newPolicy allows what user generated content usually needs, no scripts, styles or forms,
+// and iframes only when they point at https on one of the embed hosts
+func newPolicy(embedHosts []string) *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	if len(embedHosts) == 0 {
+		return p
+	}
+	quoted := make([]string, len(embedHosts))
+	for i, host := range embedHosts {
+		quoted[i] = regexp.QuoteMeta(host)
+	}
+	embedSrc := regexp.MustCompile(`^https://(` + strings.Join(quoted, "|") + `)/`)
+	p.AllowAttrs("src").Matching(embedSrc).OnElements("iframe")
+	p.AllowAttrs("width", "height").Matching(bluemonday.Number).OnElements("iframe")
+	p.AllowAttrs("title", "allowfullscreen").OnElements("iframe")
+	return p
+}
+
+// SetEmbedHosts replaces the hosts iframes may point at, it is meant to be called once at start up
+func SetEmbedHosts(hosts []string) {
+	policy = newPolicy(hosts)
+}
+
+// EmbedHostsFromEnv reads the comma separated MARKDOWN_EMBED_HOSTS, "none" turns embeds off
+func EmbedHostsFromEnv() []string {
+	value := strings.TrimSpace(os.Getenv("MARKDOWN_EMBED_HOSTS"))
+	if value == "" {
+		return DefaultEmbedHosts
+	}
+	hosts := []string{}
+	if value == "none" {
+		return hosts
+	}
+	for _, host := range strings.Split(value, ",") {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// Render converts the Markdown to sanitized HTML
+func Render(source string) string {
+	var buf bytes.Buffer
+	// Conversion only fails when writing fails, which a bytes.Buffer does not
+	_ = renderer.Convert([]byte(source), &buf)
+	return policy.Sanitize(buf.String())
+}
@@ -14,13 +14,83 @@ func SetMiddlewareJSON(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// SetMiddlewareAuthentication authenticates the request once and stores the principal in its context.
+// Requests authenticated by the session cookie must also pass the CSRF check
 func SetMiddlewareAuthentication(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		err := auth.ValidToken(r)
+		principal, err := auth.Authenticate(r)
 		if err != nil {
 			responses.ERROR(w, http.StatusUnauthorized, errors.New("Unathorized"))
 			return
 		}
+		if principal.Cookie && !auth.ValidCSRF(r) {
+			responses.ERROR(w, http.StatusForbidden, errors.New("Invalid CSRF Token"))
+			return
+		}
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}
+}
+
+// RequireRole only lets through callers whose token carries one of the roles
+func RequireRole(next http.HandlerFunc, roles ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := auth.PrincipalFromRequest(r)
+		if err != nil {
+			responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+			return
+		}
+		if !principal.HasRole(roles...) {
+			responses.ERROR(w, http.StatusForbidden, errors.New(http.StatusText(http.StatusForbidden)))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RequirePermission only lets through callers whose role grants the permission
+func RequirePermission(next http.HandlerFunc, permission auth.Permission) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := auth.PrincipalFromRequest(r)
+		if err != nil {
+			responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+			return
+		}
+		if !principal.HasPermission(permission) {
+			responses.ERROR(w, http.StatusForbidden, errors.New(http.StatusText(http.StatusForbidden)))
+			return
+		}
 		next(w, r)
 	}
-}
\ No newline at end of file
+}
+
+// RequireScope limits API keys to the routes their scopes cover, session tokens are not limited by scopes
+func RequireScope(next http.HandlerFunc, scope string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := auth.PrincipalFromRequest(r)
+		if err != nil {
+			responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+			return
+		}
+		if !principal.HasScope(scope) {
+			responses.ERROR(w, http.StatusForbidden, errors.New(http.StatusText(http.StatusForbidden)))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RequireSession keeps API keys away from account management such as creating more keys
+func RequireSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := auth.PrincipalFromRequest(r)
+		if err != nil {
+			responses.ERROR(w, http.StatusUnauthorized, errors.New("Unauthorized"))
+			return
+		}
+		if principal.AuthMethod != auth.AuthMethodJWT {
+			responses.ERROR(w, http.StatusForbidden, errors.New(http.StatusText(http.StatusForbidden)))
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+)
+
+type APIKey struct {
+	ID         uint64     `gorm:"primary_key;auto_increment" json:"id"`
+	UserID     uint32     `gorm:"not null;index" json:"user_id"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	Prefix     string     `gorm:"size:20;not null" json:"prefix"`
+	KeyHash    string     `gorm:"size:64;not null;unique" json:"-"`
+	Scopes     string     `gorm:"size:255;not null" json:"-"`
+	ScopeList  []string   `gorm:"-" json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"-"`
+	CreatedAt  time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// lastUsedResolution limits how often last_used_at is written for a busy key
+const lastUsedResolution = time.Minute
+
+func (k *APIKey) AfterFind() error {
+	k.ScopeList = []string{}
+	if k.Scopes != "" {
+		k.ScopeList = strings.Split(k.Scopes, ",")
+	}
+	return nil
+}
+
+func (k *APIKey) Prepare() {
+	k.ID = 0
+	k.Name = strings.TrimSpace(k.Name)
+	for i := range k.ScopeList {
+		k.ScopeList[i] = strings.ToLower(strings.TrimSpace(k.ScopeList[i]))
+	}
+	k.Scopes = strings.Join(k.ScopeList, ",")
+	k.LastUsedAt = nil
+	k.RevokedAt = nil
+	k.CreatedAt = time.Now()
+}
+
+func (k *APIKey) Validate() error {
+	if k.Name == "" {
+		return errors.New("Name Required")
+	}
+	if len(k.ScopeList) == 0 {
+		return errors.New("Scopes Required")
+	}
+	for _, scope := range k.ScopeList {
+		if !auth.ValidScope(scope) {
+			return errors.New("Invalid Scope")
+		}
+	}
+	if k.ExpiresAt != nil && k.ExpiresAt.Before(time.Now()) {
+		return errors.New("Expiry Must Be In The Future")
+	}
+	return nil
+}
+
+func (k *APIKey) SaveAPIKey(db *gorm.DB) (*APIKey, error) {
+	var err error
+	err = db.Debug().Model(&APIKey{}).Create(&k).Error
+	if err != nil {
+		return &APIKey{}, err
+	}
+	return k, nil
+}
+
+func (k *APIKey) UserAPIKeys(db *gorm.DB, uid uint32) (*[]APIKey, error) {
+	var err error
+	keys := []APIKey{}
+	err = db.Debug().Model(&APIKey{}).Where("user_id = ? and revoked_at is null", uid).Order("created_at desc").Find(&keys).Error
+	if err != nil {
+		return &[]APIKey{}, err
+	}
+	return &keys, nil
+}
+
+func (k *APIKey) RevokeAPIKey(db *gorm.DB, id uint64, uid uint32) (int64, error) {
+	db = db.Debug().Model(&APIKey{}).Where("id = ? and user_id = ? and revoked_at is null", id, uid).UpdateColumn("revoked_at", time.Now())
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	if db.RowsAffected == 0 {
+		return 0, errors.New("API Key Not Found")
+	}
+	return db.RowsAffected, nil
+}
+
+// DBAPIKeyStore authenticates API keys against the database for the auth package
+type DBAPIKeyStore struct {
+	DB *gorm.DB
+}
+
+func NewDBAPIKeyStore(db *gorm.DB) *DBAPIKeyStore {
+	return &DBAPIKeyStore{DB: db}
+}
+
+func (s *DBAPIKeyStore) AuthenticateAPIKey(key string) (*auth.APIKeyIdentity, error) {
+	apiKey := APIKey{}
+	now := time.Now()
+	err := s.DB.Debug().Model(&APIKey{}).Where("key_hash = ? and revoked_at is null", auth.HashToken(key)).Take(&apiKey).Error
+	if err != nil {
+		return nil, errors.New("Invalid API Key")
+	}
+	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(now) {
+		return nil, errors.New("Invalid API Key")
+	}
+	user := User{}
+	err = s.DB.Debug().Model(&User{}).Where("id = ?", apiKey.UserID).Take(&user).Error
+	if err != nil {
+		return nil, errors.New("Invalid API Key")
+	}
+	err = s.DB.Debug().Model(&APIKey{}).Where("id = ? and (last_used_at is null or last_used_at < ?)", apiKey.ID, now.Add(-lastUsedResolution)).
+		UpdateColumn("last_used_at", now).Error
+	if err != nil {
+		return nil, err
+	}
+	return &auth.APIKeyIdentity{
+		KeyID:  apiKey.ID,
+		UserID: apiKey.UserID,
+		Role:   user.Role,
+		Scopes: apiKey.ScopeList,
+	}, nil
+}
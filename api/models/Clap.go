@@ -0,0 +1,183 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// MaxClapsPerUser is how often one user may clap for one post, like on Medium
+const MaxClapsPerUser = 50
+
+// clapRetries bounds how often a clap is retried when concurrent requests of the same user get in between
+const clapRetries = 5
+
+// Clap holds how many times a user clapped for a post, Post.ClapCount is the sum over all users
+type Clap struct {
+	PostID    uint64    `gorm:"primary_key;auto_increment:false" json:"post_id"`
+	UserID    uint32    `gorm:"primary_key;auto_increment:false;index" json:"user_id"`
+	Count     int       `gorm:"not null;default:0" json:"count"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// Clapper is a user who clapped for a post, without the rest of their account
+type Clapper struct {
+	UserID   uint32    `json:"user_id"`
+	Username string    `json:"username"`
+	Count    int       `json:"count"`
+	ClapAt   time.Time `json:"clapped_at"`
+}
+
+// AddClaps adds up to n claps of the user to the post, as many as the limit leaves room for, and returns the
+// user's claps on the post afterwards. Both counts only change through compare and set or relative updates,
+// so concurrent requests neither lose claps nor get past the limit
+func (c *Clap) AddClaps(db *gorm.DB, pid uint64, uid uint32, n int) (*Clap, error) {
+	if n < 1 || n > MaxClapsPerUser {
+		return &Clap{}, errors.New("Invalid Number Of Claps")
+	}
+	var conflict error
+	for attempt := 0; attempt < clapRetries; attempt++ {
+		current := Clap{}
+		err := db.Debug().Model(&Clap{}).Where("post_id = ? and user_id = ?", pid, uid).Take(&current).Error
+		if err != nil && !gorm.IsRecordNotFoundError(err) {
+			return &Clap{}, err
+		}
+		exists := err == nil
+		if current.Count >= MaxClapsPerUser {
+			return &Clap{}, errors.New("Clap Limit Reached")
+		}
+		add := n
+		if current.Count+add > MaxClapsPerUser {
+			add = MaxClapsPerUser - current.Count
+		}
+
+		now := time.Now()
+		tx := db.Begin()
+		if exists {
+			result := tx.Debug().Model(&Clap{}).Where("post_id = ? and user_id = ? and count = ?", pid, uid, current.Count).
+				UpdateColumns(map[string]interface{}{"count": current.Count + add, "updated_at": now})
+			err = result.Error
+			if err == nil && result.RowsAffected != 1 {
+				tx.Rollback()
+				continue
+			}
+		} else {
+			// Two first claps at once collide on the primary key, the loser tries again as an update
+			err = tx.Debug().Create(&Clap{PostID: pid, UserID: uid, Count: add, CreatedAt: now, UpdatedAt: now}).Error
+			if err != nil {
+				tx.Rollback()
+				conflict = err
+				continue
+			}
+		}
+		if err != nil {
+			tx.Rollback()
+			return &Clap{}, err
+		}
+		err = tx.Debug().Model(&Post{}).Where("id = ?", pid).UpdateColumn("clap_count", gorm.Expr("clap_count + ?", add)).Error
+		if err != nil {
+			tx.Rollback()
+			return &Clap{}, err
+		}
+		err = tx.Commit().Error
+		if err != nil {
+			return &Clap{}, err
+		}
+		c.PostID, c.UserID, c.Count, c.UpdatedAt = pid, uid, current.Count+add, now
+		return c, nil
+	}
+	if conflict != nil {
+		return &Clap{}, conflict
+	}
+	return &Clap{}, errors.New("Too Many Concurrent Claps")
+}
+
+// RemoveClaps takes back all claps of the user on the post and returns how many there were
+func (c *Clap) RemoveClaps(db *gorm.DB, pid uint64, uid uint32) (int, error) {
+	for attempt := 0; attempt < clapRetries; attempt++ {
+		current := Clap{}
+		err := db.Debug().Model(&Clap{}).Where("post_id = ? and user_id = ?", pid, uid).Take(&current).Error
+		if gorm.IsRecordNotFoundError(err) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		tx := db.Begin()
+		result := tx.Debug().Where("post_id = ? and user_id = ? and count = ?", pid, uid, current.Count).Delete(&Clap{})
+		if result.Error != nil {
+			tx.Rollback()
+			return 0, result.Error
+		}
+		if result.RowsAffected != 1 {
+			tx.Rollback()
+			continue
+		}
+		err = tx.Debug().Unscoped().Model(&Post{}).Where("id = ?", pid).UpdateColumn("clap_count", gorm.Expr("clap_count - ?", current.Count)).Error
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		err = tx.Commit().Error
+		if err != nil {
+			return 0, err
+		}
+		return current.Count, nil
+	}
+	return 0, errors.New("Too Many Concurrent Claps")
+}
+
+// removeUserClaps takes back every clap of the user, for when their account is purged
+func removeUserClaps(db *gorm.DB, uid uint32) error {
+	var pids []uint64
+	err := db.Debug().Model(&Clap{}).Where("user_id = ?", uid).Pluck("post_id", &pids).Error
+	if err != nil {
+		return err
+	}
+	clap := Clap{}
+	for _, pid := range pids {
+		_, err = clap.RemoveClaps(db, pid, uid)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clappers lists who clapped for the post, most claps first
+func Clappers(db *gorm.DB, pid uint64) (*[]Clapper, error) {
+	clappers := []Clapper{}
+	err := db.Debug().Table("claps").Select("claps.user_id, users.username, claps.count, claps.updated_at as clap_at").
+		Joins("join users on users.id = claps.user_id and users.deleted_at is null").
+		Where("claps.post_id = ?", pid).Order("claps.count desc, claps.updated_at desc").Limit(100).Scan(&clappers).Error
+	if err != nil {
+		return &[]Clapper{}, err
+	}
+	return &clappers, nil
+}
+
+// MarkClapped sets Clapped on the posts the user clapped for
+func MarkClapped(db *gorm.DB, posts []Post, uid uint32) error {
+	if uid == 0 || len(posts) == 0 {
+		return nil
+	}
+	pids := make([]uint64, len(posts))
+	for i := range posts {
+		pids[i] = posts[i].ID
+	}
+	var clapped []uint64
+	err := db.Debug().Model(&Clap{}).Where("user_id = ? and post_id in (?)", uid, pids).Pluck("post_id", &clapped).Error
+	if err != nil {
+		return err
+	}
+	set := map[uint64]bool{}
+	for _, pid := range clapped {
+		set[pid] = true
+	}
+	for i := range posts {
+		posts[i].Clapped = set[posts[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/mmosoroohh/Go_Medium_API/api/markdown"
+)
+
+// MaxCommentDepth is how deep replies nest, replying deeper than that is refused
+const MaxCommentDepth = 8
+
+// Comment is a response to a post, or with a ParentID a reply to another comment on the same post
+type Comment struct {
+	ID          uint64     `gorm:"primary_key;auto_increment" json:"id"`
+	PostID      uint64     `gorm:"not null;index" json:"post_id"`
+	ParentID    *uint64    `gorm:"index" json:"parent_id"`
+	Depth       int        `gorm:"not null;default:0" json:"depth"` // 0 for responses to the post, one more for every reply level
+	Author      PublicUser `gorm:"-" json:"author"`                 // Empty once the commenter's account is in the trash or purged
+	AuthorID    uint32     `gorm:"not null;index" json:"author_id"`
+	Content     string     `gorm:"type:text;not null" json:"content"`
+	ContentHTML string     `gorm:"type:text" json:"content_html"`
+	Hidden      bool       `gorm:"not null;default:false" json:"hidden"`  // Hidden by the post's author, only they and the commenter still see it
+	Deleted     bool       `gorm:"not null;default:false" json:"deleted"` // Deleted, but kept because others replied to it
+	CreatedAt   time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	Replies     []*Comment `gorm:"-" json:"replies,omitempty"`
+}
+
+func (c *Comment) Prepare() {
+	c.ID = 0
+	c.Content = strings.TrimSpace(c.Content)
+	c.ContentHTML = markdown.Render(c.Content)
+	c.Author = PublicUser{}
+	c.Depth = 0
+	c.Hidden = false
+	c.Deleted = false
+	c.Replies = nil
+	c.CreatedAt = time.Now()
+	c.UpdatedAt = time.Now()
+}
+
+func (c *Comment) Validate() error {
+	if c.Content == "" {
+		return errors.New("Content Required")
+	}
+	if c.AuthorID < 1 {
+		return errors.New("Author Required")
+	}
+	return nil
+}
+
+// SaveComment stores a new comment, a reply has to be to a comment on the same post that is not nested too deep
+func (c *Comment) SaveComment(db *gorm.DB) (*Comment, error) {
+	var err error
+	if c.ParentID != nil {
+		parent := Comment{}
+		err = db.Debug().Model(&Comment{}).Where("id = ? and post_id = ?", *c.ParentID, c.PostID).Take(&parent).Error
+		if gorm.IsRecordNotFoundError(err) {
+			return &Comment{}, errors.New("Parent Comment Not Found")
+		}
+		if err != nil {
+			return &Comment{}, err
+		}
+		if parent.Depth+1 > MaxCommentDepth {
+			return &Comment{}, errors.New("Thread Too Deep")
+		}
+		c.Depth = parent.Depth + 1
+	}
+	err = db.Debug().Model(&Comment{}).Create(&c).Error
+	if err != nil {
+		return &Comment{}, err
+	}
+	err = c.loadAuthor(db)
+	if err != nil {
+		return &Comment{}, err
+	}
+	return c, nil
+}
+
+// loadAuthor sets what anyone may see of the commenter, users in the trash or purged are left out
+func (c *Comment) loadAuthor(db *gorm.DB) error {
+	c.Author = PublicUser{}
+	err := db.Debug().Model(&User{}).Select("id, username").Where("id = ?", c.AuthorID).Scan(&c.Author).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *Comment) FindComment(db *gorm.DB, pid, cid uint64) (*Comment, error) {
+	var err error
+	err = db.Debug().Model(&Comment{}).Where("id = ? and post_id = ?", cid, pid).Take(&c).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &Comment{}, errors.New("Comment Not Found")
+	}
+	if err != nil {
+		return &Comment{}, err
+	}
+	return c, nil
+}
+
+// PostComments returns all comments on the post, oldest first, with what anyone may see of their authors
+func (c *Comment) PostComments(db *gorm.DB, pid uint64) (*[]Comment, error) {
+	var err error
+	comments := []Comment{}
+	err = db.Debug().Model(&Comment{}).Where("post_id = ?", pid).Order("created_at, id").Limit(1000).Find(&comments).Error
+	if err != nil {
+		return &[]Comment{}, err
+	}
+	uids := []uint32{}
+	for i := range comments {
+		uids = append(uids, comments[i].AuthorID)
+	}
+	users := []PublicUser{}
+	if len(uids) > 0 {
+		// Comments of users in the trash or purged, kept for the replies, show without an author
+		err = db.Debug().Model(&User{}).Select("id, username").Where("id in (?)", uids).Scan(&users).Error
+		if err != nil {
+			return &[]Comment{}, err
+		}
+	}
+	authors := map[uint32]PublicUser{}
+	for _, user := range users {
+		authors[user.ID] = user
+	}
+	for i := range comments {
+		comments[i].Author = authors[comments[i].AuthorID]
+	}
+	return &comments, nil
+}
+
+func (c *Comment) UpdateComment(db *gorm.DB) (*Comment, error) {
+	var err error
+	c.UpdatedAt = time.Now()
+	err = db.Debug().Model(&Comment{}).Where("id = ?", c.ID).UpdateColumns(map[string]interface{}{
+		"content":      c.Content,
+		"content_html": c.ContentHTML,
+		"updated_at":   c.UpdatedAt,
+	}).Error
+	if err != nil {
+		return &Comment{}, err
+	}
+	err = c.loadAuthor(db)
+	if err != nil {
+		return &Comment{}, err
+	}
+	return c, nil
+}
+
+// SetHidden hides the comment from everyone but the post's author and the commenter, or shows it again
+func (c *Comment) SetHidden(db *gorm.DB, hidden bool) (*Comment, error) {
+	err := db.Debug().Model(&Comment{}).Where("id = ?", c.ID).UpdateColumn("hidden", hidden).Error
+	if err != nil {
+		return &Comment{}, err
+	}
+	c.Hidden = hidden
+	return c, nil
+}
+
+// DeleteComment deletes the comment. One that has replies stays behind, emptied, so the thread keeps its shape
+func (c *Comment) DeleteComment(db *gorm.DB) error {
+	replies := 0
+	err := db.Debug().Model(&Comment{}).Where("parent_id = ?", c.ID).Count(&replies).Error
+	if err != nil {
+		return err
+	}
+	if replies > 0 {
+		return db.Debug().Model(&Comment{}).Where("id = ?", c.ID).UpdateColumns(map[string]interface{}{
+			"content":      "",
+			"content_html": "",
+			"deleted":      true,
+			"updated_at":   time.Now(),
+		}).Error
+	}
+	return db.Debug().Where("id = ?", c.ID).Delete(&Comment{}).Error
+}
+
+// deleteUserComments deletes every comment of the user, for when their account is purged
+func deleteUserComments(db *gorm.DB, uid uint32) error {
+	comments := []Comment{}
+	err := db.Debug().Model(&Comment{}).Where("author_id = ?", uid).Order("depth desc").Find(&comments).Error
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		err = comments[i].DeleteComment(db)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CommentCounts returns how many comments anyone can see on each of the posts, by post id
+func CommentCounts(db *gorm.DB, pids []uint64) (map[uint64]int, error) {
+	counts := map[uint64]int{}
+	if len(pids) == 0 {
+		return counts, nil
+	}
+	rows, err := db.Debug().Model(&Comment{}).Select("post_id, count(*)").
+		Where("post_id in (?) and hidden = ? and deleted = ?", pids, false, false).Group("post_id").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pid uint64
+		var count int
+		err = rows.Scan(&pid, &count)
+		if err != nil {
+			return nil, err
+		}
+		counts[pid] = count
+	}
+	return counts, rows.Err()
+}
+
+// ThreadComments nests the replies under the comments they answer, comments whose parent is missing become
+// responses to the post
+func ThreadComments(comments []Comment) []*Comment {
+	byID := map[uint64]*Comment{}
+	for i := range comments {
+		comments[i].Replies = nil
+		byID[comments[i].ID] = &comments[i]
+	}
+	roots := []*Comment{}
+	for i := range comments {
+		c := &comments[i]
+		if c.ParentID != nil {
+			if parent, ok := byID[*c.ParentID]; ok {
+				parent.Replies = append(parent.Replies, c)
+				continue
+			}
+		}
+		roots = append(roots, c)
+	}
+	return roots
+}
+
+// FlattenComments lists the thread in reading order, every comment followed by its replies, Depth tells the nesting
+func FlattenComments(roots []*Comment) []Comment {
+	flat := []Comment{}
+	var walk func(comments []*Comment)
+	walk = func(comments []*Comment) {
+		for _, c := range comments {
+			replies := c.Replies
+			comment := *c
+			comment.Replies = nil
+			flat = append(flat, comment)
+			walk(replies)
+		}
+	}
+	walk(roots)
+	return flat
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type EmailVerification struct {
+	ID        uint64     `gorm:"primary_key;auto_increment" json:"id"`
+	UserID    uint32     `gorm:"not null;index" json:"user_id"`
+	Email     string     `gorm:"size:100" json:"email"` // The address the token was sent to, it only verifies that one
+	TokenHash string     `gorm:"size:64;not null;unique" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (ev *EmailVerification) SaveEmailVerification(db *gorm.DB) (*EmailVerification, error) {
+	var err error
+	ev.CreatedAt = time.Now()
+	err = db.Debug().Model(&EmailVerification{}).Create(&ev).Error
+	if err != nil {
+		return &EmailVerification{}, err
+	}
+	return ev, nil
+}
+
+func (ev *EmailVerification) FindEmailVerification(db *gorm.DB, tokenHash string) (*EmailVerification, error) {
+	var err error
+	err = db.Debug().Model(&EmailVerification{}).Where("token_hash = ?", tokenHash).Take(&ev).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &EmailVerification{}, errors.New("Verification Token Not Found")
+	}
+	if err != nil {
+		return &EmailVerification{}, err
+	}
+	return ev, nil
+}
+
+// UseEmailVerification consumes the token and marks the user's email as verified, as long as the user still has the
+// address the token was sent to
+func (ev *EmailVerification) UseEmailVerification(db *gorm.DB) error {
+	now := time.Now()
+	tx := db.Begin()
+	result := tx.Debug().Model(&EmailVerification{}).Where("id = ? and used_at is null and expires_at > ?", ev.ID, now).UpdateColumn("used_at", now)
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		tx.Rollback()
+		return errors.New("Invalid or Expired Token")
+	}
+	result = tx.Debug().Model(&User{}).Where("id = ? and email = ?", ev.UserID, ev.Email).UpdateColumn("email_verified_at", now)
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		tx.Rollback()
+		return errors.New("Invalid or Expired Token")
+	}
+	ev.UsedAt = &now
+	return tx.Commit().Error
+}
+
+// RecentEmailVerifications returns the verification mails sent to the user since the given time, newest first
+func (ev *EmailVerification) RecentEmailVerifications(db *gorm.DB, uid uint32, since time.Time) (*[]EmailVerification, error) {
+	var err error
+	verifications := []EmailVerification{}
+	err = db.Debug().Model(&EmailVerification{}).Where("user_id = ? and created_at > ?", uid, since).Order("created_at desc").Find(&verifications).Error
+	if err != nil {
+		return &[]EmailVerification{}, err
+	}
+	return &verifications, nil
+}
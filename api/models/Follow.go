@@ -0,0 +1,109 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Follow is a user following an author, the feed shows them the posts of everyone they follow
+type Follow struct {
+	FollowerID  uint32    `gorm:"primary_key;auto_increment:false" json:"follower_id"`
+	FollowingID uint32    `gorm:"primary_key;auto_increment:false;index" json:"following_id"`
+	CreatedAt   time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// FollowUser is one side of a follow, without the rest of their account
+type FollowUser struct {
+	UserID     uint32    `json:"user_id"`
+	Username   string    `json:"username"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// FollowList is a page of followers or followed users, Count is how many there are in total
+type FollowList struct {
+	Count int          `json:"count"`
+	Users []FollowUser `json:"users"`
+}
+
+func (f *Follow) following(db *gorm.DB, follower, following uint32) (bool, error) {
+	count := 0
+	err := db.Debug().Model(&Follow{}).Where("follower_id = ? and following_id = ?", follower, following).Count(&count).Error
+	return count > 0, err
+}
+
+// FollowUser makes the follower follow the other user, following yourself or someone twice is refused
+func (f *Follow) FollowUser(db *gorm.DB, follower, following uint32) (*Follow, error) {
+	if follower == following {
+		return &Follow{}, errors.New("Cannot Follow Yourself")
+	}
+	exists, err := f.following(db, follower, following)
+	if err != nil {
+		return &Follow{}, err
+	}
+	if exists {
+		return &Follow{}, errors.New("Already Following")
+	}
+	f.FollowerID, f.FollowingID, f.CreatedAt = follower, following, time.Now()
+	err = db.Debug().Create(&f).Error
+	if err != nil {
+		// Two follows at once collide on the primary key
+		exists, existsErr := f.following(db, follower, following)
+		if existsErr == nil && exists {
+			return &Follow{}, errors.New("Already Following")
+		}
+		return &Follow{}, err
+	}
+	return f, nil
+}
+
+// UnfollowUser stops the follower from following the other user
+func (f *Follow) UnfollowUser(db *gorm.DB, follower, following uint32) error {
+	result := db.Debug().Where("follower_id = ? and following_id = ?", follower, following).Delete(&Follow{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("Not Following")
+	}
+	return nil
+}
+
+// IsFollowing tells whether the follower follows the other user
+func (f *Follow) IsFollowing(db *gorm.DB, follower, following uint32) (bool, error) {
+	return f.following(db, follower, following)
+}
+
+// Followers lists who follows the user, latest first
+func Followers(db *gorm.DB, uid uint32) (*FollowList, error) {
+	return followList(db, "follows.following_id", "follows.follower_id", uid)
+}
+
+// Following lists whom the user follows, latest first
+func Following(db *gorm.DB, uid uint32) (*FollowList, error) {
+	return followList(db, "follows.follower_id", "follows.following_id", uid)
+}
+
+// followList lists the users on the other side of the user's follows, deleted users are left out of the list and
+// the count alike
+func followList(db *gorm.DB, column, other string, uid uint32) (*FollowList, error) {
+	list := FollowList{Users: []FollowUser{}}
+	query := db.Debug().Table("follows").Joins("join users on users.id = "+other+" and users.deleted_at is null").
+		Where(column+" = ?", uid)
+	err := query.Count(&list.Count).Error
+	if err != nil {
+		return &FollowList{}, err
+	}
+	err = query.Select("users.id as user_id, users.username, follows.created_at as followed_at").
+		Order("follows.created_at desc").Limit(100).Scan(&list.Users).Error
+	if err != nil {
+		return &FollowList{}, err
+	}
+	return &list, nil
+}
+
+// removeUserFollows deletes the follows from and to the user, for when their account is purged
+func removeUserFollows(db *gorm.DB, uid uint32) error {
+	return db.Debug().Where("follower_id = ? or following_id = ?", uid, uid).Delete(&Follow{}).Error
+}
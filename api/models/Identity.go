@@ -0,0 +1,50 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Identity links an account at an external identity provider to a user
+type Identity struct {
+	ID        uint64    `gorm:"primary_key;auto_increment" json:"id"`
+	UserID    uint32    `gorm:"not null;index" json:"user_id"`
+	Provider  string    `gorm:"size:50;not null;unique_index:idx_identity_provider_subject" json:"provider"`
+	Subject   string    `gorm:"size:255;not null;unique_index:idx_identity_provider_subject" json:"subject"`
+	Email     string    `gorm:"size:100" json:"email"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (i *Identity) SaveIdentity(db *gorm.DB) (*Identity, error) {
+	var err error
+	i.CreatedAt = time.Now()
+	err = db.Debug().Model(&Identity{}).Create(&i).Error
+	if err != nil {
+		return &Identity{}, err
+	}
+	return i, nil
+}
+
+func (i *Identity) FindIdentity(db *gorm.DB, provider, subject string) (*Identity, error) {
+	var err error
+	err = db.Debug().Model(&Identity{}).Where("provider = ? and subject = ?", provider, subject).Take(&i).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &Identity{}, errors.New("Identity Not Found")
+	}
+	if err != nil {
+		return &Identity{}, err
+	}
+	return i, nil
+}
+
+func (i *Identity) UserIdentities(db *gorm.DB, uid uint32) (*[]Identity, error) {
+	var err error
+	identities := []Identity{}
+	err = db.Debug().Model(&Identity{}).Where("user_id = ?", uid).Find(&identities).Error
+	if err != nil {
+		return &[]Identity{}, err
+	}
+	return &identities, nil
+}
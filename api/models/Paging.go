@@ -0,0 +1,147 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+	"github.com/mmosoroohh/Go_Medium_API/api/utils/cursor"
+)
+
+// How many items a page holds when the caller does not say, and at most
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// Paging asks for one page of a listing sorted by one column, with ties broken by id. Cursor continues from the
+// page before, it overrides Sort and Desc with the ones it was made for
+type Paging struct {
+	Sort   string
+	Desc   bool
+	Cursor *cursor.Cursor
+	Limit  int
+}
+
+// PageInfo tells where a page sits in the listing. Next and Prev lead to the neighbouring pages and are nil when
+// there is none
+type PageInfo struct {
+	Total int
+	Next  *cursor.Cursor
+	Prev  *cursor.Cursor
+}
+
+// PostSorts and UserSorts are the columns posts and users can be listed by
+var (
+	PostSorts = []string{"created_at", "updated_at", "title"}
+	UserSorts = []string{"created_at", "updated_at", "username"}
+)
+
+// textSorts are the sort columns compared as text, all others hold times
+var textSorts = map[string]bool{"title": true, "username": true}
+
+// normalize fills in the defaults and caps the limit, the listing is latest first unless asked otherwise. The sort
+// ends up in the query, so it has to be one of the listing's columns, also when it comes from a cursor
+func (pg *Paging) normalize(defaultSort string, sorts ...string) error {
+	if pg.Cursor != nil {
+		pg.Sort, pg.Desc = pg.Cursor.Sort, pg.Cursor.Desc
+	}
+	if pg.Sort == "" {
+		pg.Sort, pg.Desc = defaultSort, true
+	}
+	if pg.Limit < 1 {
+		pg.Limit = DefaultPageLimit
+	}
+	if pg.Limit > MaxPageLimit {
+		pg.Limit = MaxPageLimit
+	}
+	for _, sort := range append(sorts, defaultSort) {
+		if pg.Sort == sort {
+			return nil
+		}
+	}
+	return errors.New("Invalid Sort")
+}
+
+// backward tells whether the page is fetched walking back from the cursor
+func (pg *Paging) backward() bool {
+	return pg.Cursor != nil && pg.Cursor.Prev
+}
+
+// apply narrows the query down to the page, sortColumn and idColumn name the columns in the query. It asks for one
+// row more than the limit to tell whether the listing goes on
+func (pg *Paging) apply(query *gorm.DB, sortColumn, idColumn string) *gorm.DB {
+	if pg.Cursor != nil {
+		var value interface{} = pg.Cursor.Time
+		if textSorts[pg.Sort] {
+			value = pg.Cursor.Text
+		}
+		op := ">"
+		if pg.Desc != pg.Cursor.Prev {
+			op = "<"
+		}
+		query = query.Where(fmt.Sprintf("%s %s ? or (%s = ? and %s %s ?)", sortColumn, op, sortColumn, idColumn, op), value, value, pg.Cursor.ID)
+	}
+	direction := "asc"
+	if pg.Desc != pg.backward() {
+		direction = "desc"
+	}
+	return query.Order(sortColumn + " " + direction).Order(idColumn + " " + direction).Limit(pg.Limit + 1)
+}
+
+// keep works out a fetched page: how many of the rows belong on it, and whether there are more pages after and
+// before it. Walking back, the rows still have to be reversed
+func (pg *Paging) keep(fetched int) (int, bool, bool) {
+	more := fetched > pg.Limit
+	if more {
+		fetched = pg.Limit
+	}
+	if pg.backward() {
+		return fetched, true, more
+	}
+	return fetched, more, pg.Cursor != nil
+}
+
+// info makes the page's cursors from the sort values of its first and last item
+func (pg *Paging) info(total int, hasNext, hasPrev bool, first, last *cursor.Cursor) PageInfo {
+	info := PageInfo{Total: total}
+	if first == nil {
+		return info
+	}
+	if hasNext {
+		next := *last
+		next.Sort, next.Desc = pg.Sort, pg.Desc
+		info.Next = &next
+	}
+	if hasPrev {
+		prev := *first
+		prev.Sort, prev.Desc, prev.Prev = pg.Sort, pg.Desc, true
+		info.Prev = &prev
+	}
+	return info
+}
+
+// offset is where a page of a listing ranked by relevance starts, such listings page by position instead of by value
+func (pg *Paging) offset() int {
+	if pg.Cursor == nil || pg.Cursor.Offset < 0 {
+		return 0
+	}
+	return pg.Cursor.Offset
+}
+
+// offsetInfo makes the cursors of a page of n items of a listing ranked by relevance
+func (pg *Paging) offsetInfo(total, n int) PageInfo {
+	info := PageInfo{Total: total}
+	offset := pg.offset()
+	if offset+n < total {
+		info.Next = &cursor.Cursor{Sort: pg.Sort, Desc: pg.Desc, Offset: offset + n}
+	}
+	if offset > 0 {
+		prev := offset - pg.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		info.Prev = &cursor.Cursor{Sort: pg.Sort, Desc: pg.Desc, Offset: prev}
+	}
+	return info
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type PasswordReset struct {
+	ID        uint64     `gorm:"primary_key;auto_increment" json:"id"`
+	UserID    uint32     `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"size:64;not null;unique" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (pr *PasswordReset) SavePasswordReset(db *gorm.DB) (*PasswordReset, error) {
+	var err error
+	err = db.Debug().Model(&PasswordReset{}).Create(&pr).Error
+	if err != nil {
+		return &PasswordReset{}, err
+	}
+	return pr, nil
+}
+
+func (pr *PasswordReset) FindPasswordReset(db *gorm.DB, tokenHash string) (*PasswordReset, error) {
+	var err error
+	err = db.Debug().Model(&PasswordReset{}).Where("token_hash = ?", tokenHash).Take(&pr).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &PasswordReset{}, errors.New("Reset Token Not Found")
+	}
+	if err != nil {
+		return &PasswordReset{}, err
+	}
+	return pr, nil
+}
+
+// UsePasswordReset consumes the reset token, a token can only ever be used once
+func (pr *PasswordReset) UsePasswordReset(db *gorm.DB) error {
+	now := time.Now()
+	db = db.Debug().Model(&PasswordReset{}).Where("id = ? and used_at is null and expires_at > ?", pr.ID, now).UpdateColumn("used_at", now)
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected != 1 {
+		return errors.New("Invalid or Expired Token")
+	}
+	pr.UsedAt = &now
+	return nil
+}
@@ -1,33 +1,133 @@
 package models
 
 import (
+	"encoding/json"
 	"errors"
 	"html"
 	"strings"
 	"time"
 
 	"github.com/jinzhu/gorm"
+	"github.com/mmosoroohh/Go_Medium_API/api/markdown"
+	"github.com/mmosoroohh/Go_Medium_API/api/utils/cursor"
+)
+
+const (
+	PostStatusDraft     = "draft"     // Only visible to the author
+	PostStatusPublished = "published" // Visible to anyone and listed
+	PostStatusUnlisted  = "unlisted"  // Visible to anyone with the link, but not listed
+	PostStatusArchived  = "archived"  // Taken down, only visible to the author
 )
 
 type Post struct {
-	ID        uint64    `gorm:"primary_key;auto_increment" json:"id"`
-	Title     string    `gorm:"size:255;not null;unique" json:"title"`
-	Content   string    `gorm:"size:255;not null;" json:"content"`
-	Author    User      `json:"author"`
-	AuthorID  uint32    `gorm:"not null" json:"author_id"`
-	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	ID           uint64     `gorm:"primary_key;auto_increment" json:"id"`
+	Title        string     `gorm:"size:255;not null;unique" json:"title"`
+	Slug         string     `gorm:"size:255;unique_index" json:"slug"`
+	Content      string     `gorm:"type:text;not null;" json:"content_markdown"`
+	ContentHTML  string     `gorm:"type:text" json:"content_html"` // Rendered from Content on every save, never taken from clients
+	Author       User       `json:"author"`
+	AuthorID     uint32     `gorm:"not null" json:"author_id"`
+	Status       string     `gorm:"size:20;not null;default:'draft';index" json:"status"`
+	PublishedAt  *time.Time `json:"published_at"`
+	PublishAt    *time.Time `gorm:"index" json:"publish_at"` // Set while the post is scheduled to go live later
+	Tags         []string   `gorm:"-" json:"tags"`           // Stored in post_tags, nil on updates leaves them as they are
+	EditorID     uint32     `gorm:"-" json:"-"`              // Who makes the change, recorded on the revision; the author if not set
+	ClapCount    int64      `gorm:"not null;default:0" json:"clap_count"`
+	Clapped      bool       `gorm:"-" json:"clapped"`       // Whether the user asking clapped for the post, see MarkClapped
+	CommentCount int        `gorm:"-" json:"comment_count"` // Comments everyone can see, hidden and deleted ones do not count
+	Bookmarked   bool       `gorm:"-" json:"is_bookmarked"` // Whether the post is in the reading list of the user asking, see MarkBookmarked
+	CreatedAt    time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+	DeletedAt    *time.Time `gorm:"index" json:"deleted_at"` // Set while the post is in the trash, gorm leaves those out of every query
+}
+
+// PostFilter narrows down AllPosts, zero values match everything
+type PostFilter struct {
+	Status        string
+	AuthorID      uint32
+	VisibleAt     time.Time // Hides posts scheduled to go live after this time
+	Scheduled     bool      // Only posts that are still scheduled
+	Tag           string    // Only posts with this (normalized) tag
+	CreatedAfter  time.Time // Only posts created at or after this time
+	CreatedBefore time.Time // Only posts created before this time
+}
+
+// UnmarshalJSON also takes the content from "content", which clients sent before posts were written in Markdown
+func (p *Post) UnmarshalJSON(data []byte) error {
+	type post Post
+	aux := struct {
+		*post
+		LegacyContent *string `json:"content"`
+	}{post: (*post)(p)}
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+	if p.Content == "" && aux.LegacyContent != nil {
+		p.Content = *aux.LegacyContent
+	}
+	return nil
+}
+
+func ValidPostStatus(status string) bool {
+	switch status {
+	case PostStatusDraft, PostStatusPublished, PostStatusUnlisted, PostStatusArchived:
+		return true
+	}
+	return false
+}
+
+// Public reports whether anyone may read the post, drafts and archived posts are only for their author
+func (p *Post) Public() bool {
+	return p.Status == PostStatusPublished || p.Status == PostStatusUnlisted
+}
+
+// Visible reports whether anyone may read the post at the given time, public posts are hidden until they are due
+func (p *Post) Visible(now time.Time) bool {
+	return p.Public() && (p.PublishAt == nil || !p.PublishAt.After(now))
 }
 
 func (p *Post) Prepare() {
 	p.ID = 0
 	p.Title = html.EscapeString(strings.TrimSpace(p.Title))
-	p.Content = html.EscapeString(strings.TrimSpace(p.Content))
+	p.Content = strings.TrimSpace(p.Content)
+	p.ContentHTML = markdown.Render(p.Content)
 	p.Author = User{}
+	p.Slug = "" // Slugs are always derived from the title
+	p.Tags = NormalizeTags(p.Tags)
+	p.ClapCount = 0
+	p.Status = strings.ToLower(strings.TrimSpace(p.Status))
+	if p.Status == "" {
+		p.Status = PostStatusDraft
+	}
+	p.PublishedAt = nil
+	now := time.Now()
+	if !p.Public() || (p.PublishAt != nil && !p.PublishAt.After(now)) {
+		p.PublishAt = nil
+	}
+	if p.Public() && p.PublishAt == nil {
+		p.PublishedAt = &now
+	}
 	p.CreatedAt = time.Now()
 	p.UpdatedAt = time.Now()
 }
 
+// BeforeCreate gives every new post a slug and rendered content, also when it is created without SavePost
+func (p *Post) BeforeCreate(db *gorm.DB) error {
+	if p.ContentHTML == "" {
+		p.ContentHTML = markdown.Render(p.Content)
+	}
+	if p.Slug != "" {
+		return nil
+	}
+	s, err := UniqueSlug(db, p.Title, 0)
+	if err != nil {
+		return err
+	}
+	p.Slug = s
+	return nil
+}
+
 func (p *Post) Validate() error {
 
 	if p.Title == "" {
@@ -39,6 +139,12 @@ func (p *Post) Validate() error {
 	if p.AuthorID < 1 {
 		return errors.New("Author Required")
 	}
+	if p.Status != "" && !ValidPostStatus(p.Status) {
+		return errors.New("Invalid Status")
+	}
+	if len(p.Tags) > MaxTagsPerPost {
+		return errors.New("Too Many Tags")
+	}
 	return nil
 }
 
@@ -48,6 +154,20 @@ func (p *Post) SavePost(db *gorm.DB) (*Post, error) {
 	if err != nil {
 		return &Post{}, err
 	}
+	err = addRevision(db, p, p.EditorID, p.CreatedAt)
+	if err != nil {
+		return &Post{}, err
+	}
+	if p.Tags != nil {
+		err = SetPostTags(db, p.ID, p.Tags)
+		if err != nil {
+			return &Post{}, err
+		}
+	}
+	err = p.loadTags(db)
+	if err != nil {
+		return &Post{}, err
+	}
 	if p.ID != 0 {
 		err = db.Debug().Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
 		if err != nil {
@@ -57,22 +177,127 @@ func (p *Post) SavePost(db *gorm.DB) (*Post, error) {
 	return p, nil
 }
 
-func (p *Post) AllPosts(db *gorm.DB) (*[]Post, error) {
-	var err error
+// AllPosts returns a page of the posts that match the filter, see Paging
+func (p *Post) AllPosts(db *gorm.DB, filter PostFilter, paging Paging) (*[]Post, PageInfo, error) {
+	err := paging.normalize("created_at", PostSorts...)
+	if err != nil {
+		return &[]Post{}, PageInfo{}, err
+	}
+	query := db.Debug().Model(&Post{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.AuthorID != 0 {
+		query = query.Where("author_id = ?", filter.AuthorID)
+	}
+	if !filter.VisibleAt.IsZero() {
+		query = query.Where("publish_at is null or publish_at <= ?", filter.VisibleAt)
+	}
+	if filter.Scheduled {
+		query = query.Where("publish_at is not null")
+	}
+	if filter.Tag != "" {
+		query = query.Where("id in (?)", db.Table("post_tags").Select("post_tags.post_id").
+			Joins("join tags on tags.id = post_tags.tag_id").Where("tags.name = ?", filter.Tag).SubQuery())
+	}
+	if !filter.CreatedAfter.IsZero() {
+		query = query.Where("created_at >= ?", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		query = query.Where("created_at < ?", filter.CreatedBefore)
+	}
+	return pagePosts(db, query, &paging)
+}
+
+// FeedPosts returns a page of the visible posts of the authors the user follows, latest published first
+func (p *Post) FeedPosts(db *gorm.DB, uid uint32, now time.Time, paging Paging) (*[]Post, PageInfo, error) {
+	err := paging.normalize("published_at")
+	if err != nil {
+		return &[]Post{}, PageInfo{}, err
+	}
+	query := db.Debug().Model(&Post{}).
+		Where("author_id in (?)", db.Table("follows").Select("following_id").Where("follower_id = ?", uid).SubQuery()).
+		Where("status = ? and published_at is not null", PostStatusPublished).
+		Where("publish_at is null or publish_at <= ?", now)
+	return pagePosts(db, query, &paging)
+}
+
+// pagePosts fetches the page of the posts the query matches, with their details
+func pagePosts(db *gorm.DB, query *gorm.DB, paging *Paging) (*[]Post, PageInfo, error) {
+	total := 0
+	err := query.Count(&total).Error
+	if err != nil {
+		return &[]Post{}, PageInfo{}, err
+	}
 	posts := []Post{}
-	err = db.Debug().Model(&Post{}).Limit(100).Find(&posts).Error
+	err = paging.apply(query, "posts."+paging.Sort, "posts.id").Find(&posts).Error
 	if err != nil {
-		return &[]Post{}, err
+		return &[]Post{}, PageInfo{}, err
 	}
-	if len(posts) > 0 {
-		for i, _ := range posts {
-			err := db.Debug().Model(&User{}).Where("id = ?", posts[i].AuthorID).Take(&posts[i].Author).Error
-			if err != nil {
-				return &[]Post{}, err
-			}
+	n, hasNext, hasPrev := paging.keep(len(posts))
+	posts = posts[:n]
+	if paging.backward() {
+		for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+			posts[i], posts[j] = posts[j], posts[i]
 		}
 	}
-	return &posts, nil
+	err = loadPostDetails(db, posts)
+	if err != nil {
+		return &[]Post{}, PageInfo{}, err
+	}
+	var first, last *cursor.Cursor
+	if n > 0 {
+		first, last = posts[0].pageKey(paging.Sort), posts[n-1].pageKey(paging.Sort)
+	}
+	return &posts, paging.info(total, hasNext, hasPrev, first, last), nil
+}
+
+// pageKey is where the post sits in a listing sorted by the column
+func (p *Post) pageKey(sort string) *cursor.Cursor {
+	switch sort {
+	case "title":
+		return &cursor.Cursor{Text: p.Title, ID: p.ID}
+	case "updated_at":
+		return &cursor.Cursor{Time: p.UpdatedAt, ID: p.ID}
+	case "published_at":
+		if p.PublishedAt != nil {
+			return &cursor.Cursor{Time: *p.PublishedAt, ID: p.ID}
+		}
+	}
+	return &cursor.Cursor{Time: p.CreatedAt, ID: p.ID}
+}
+
+// loadPostDetails fills in the authors, tags and comment counts of a list of posts
+func loadPostDetails(db *gorm.DB, posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	for i, _ := range posts {
+		err := db.Debug().Model(&User{}).Where("id = ?", posts[i].AuthorID).Take(&posts[i].Author).Error
+		if err != nil {
+			return err
+		}
+	}
+	pids := make([]uint64, len(posts))
+	for i := range posts {
+		pids[i] = posts[i].ID
+	}
+	tags, err := PostTagNames(db, pids)
+	if err != nil {
+		return err
+	}
+	comments, err := CommentCounts(db, pids)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].CommentCount = comments[posts[i].ID]
+		posts[i].Tags = tags[posts[i].ID]
+		if posts[i].Tags == nil {
+			posts[i].Tags = []string{}
+		}
+	}
+	return nil
 }
 
 func (p *Post) SinglePost(db *gorm.DB, pid uint64) (*Post, error) {
@@ -81,6 +306,10 @@ func (p *Post) SinglePost(db *gorm.DB, pid uint64) (*Post, error) {
 	if err != nil {
 		return &Post{}, err
 	}
+	err = p.loadTags(db)
+	if err != nil {
+		return &Post{}, err
+	}
 	if p.ID != 0 {
 		err = db.Debug().Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
 		if err != nil {
@@ -90,9 +319,115 @@ func (p *Post) SinglePost(db *gorm.DB, pid uint64) (*Post, error) {
 	return p, nil
 }
 
+func (p *Post) SinglePostBySlug(db *gorm.DB, s string) (*Post, error) {
+	var err error
+	err = db.Debug().Model(&Post{}).Where("slug = ?", s).Take(&p).Error
+	if err != nil {
+		return &Post{}, err
+	}
+	err = p.loadTags(db)
+	if err != nil {
+		return &Post{}, err
+	}
+	err = db.Debug().Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
+	if err != nil {
+		return &Post{}, err
+	}
+	return p, nil
+}
+
+// loadTags fills in the tags of the post, always as a list so clients do not see null, and its comment count
+func (p *Post) loadTags(db *gorm.DB) error {
+	tags, err := PostTagNames(db, []uint64{p.ID})
+	if err != nil {
+		return err
+	}
+	comments, err := CommentCounts(db, []uint64{p.ID})
+	if err != nil {
+		return err
+	}
+	p.CommentCount = comments[p.ID]
+	p.Tags = tags[p.ID]
+	if p.Tags == nil {
+		p.Tags = []string{}
+	}
+	return nil
+}
+
+// UpdatePost saves the title, content and tags and records them as a new revision.
+// A new title gets a new slug, the old one is kept to redirect from
 func (p *Post) UpdatePost(db *gorm.DB) (*Post, error) {
 	var err error
-	err = db.Debug().Model(&Post{}).Where("id = ?", p.ID).Updates(Post{Title: p.Title, Content: p.Content, UpdatedAt: time.Now()}).Error
+	current := Post{}
+	err = db.Debug().Model(&Post{}).Where("id = ?", p.ID).Take(&current).Error
+	if err != nil {
+		return &Post{}, err
+	}
+	p.Slug = current.Slug
+	if p.Title != current.Title {
+		p.Slug, err = UniqueSlug(db, p.Title, p.ID)
+		if err != nil {
+			return &Post{}, err
+		}
+	}
+
+	tx := db.Begin()
+	// Revisions are numbered after the newest one, so saves of the same post take turns until they commit
+	err = lockPost(tx, p.ID)
+	if err != nil {
+		tx.Rollback()
+		return &Post{}, err
+	}
+	// Posts written before revisions were kept get their current version recorded first
+	revisions := 0
+	err = tx.Debug().Model(&PostRevision{}).Where("post_id = ?", p.ID).Count(&revisions).Error
+	if err != nil {
+		tx.Rollback()
+		return &Post{}, err
+	}
+	if revisions == 0 {
+		err = addRevision(tx, &current, current.AuthorID, current.UpdatedAt)
+		if err != nil {
+			tx.Rollback()
+			return &Post{}, err
+		}
+	}
+	err = tx.Debug().Model(&Post{}).Where("id = ?", p.ID).Updates(Post{Title: p.Title, Slug: p.Slug, Content: p.Content, ContentHTML: p.ContentHTML, UpdatedAt: time.Now()}).Error
+	if err != nil {
+		tx.Rollback()
+		return &Post{}, err
+	}
+	err = addRevision(tx, p, p.EditorID, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return &Post{}, err
+	}
+	if p.Slug != current.Slug {
+		// Going back to an old title makes its slug current again
+		err = tx.Debug().Where("post_id = ? and slug = ?", p.ID, p.Slug).Delete(&PostSlug{}).Error
+		if err != nil {
+			tx.Rollback()
+			return &Post{}, err
+		}
+		if current.Slug != "" {
+			err = tx.Debug().Create(&PostSlug{PostID: p.ID, Slug: current.Slug, CreatedAt: time.Now()}).Error
+			if err != nil {
+				tx.Rollback()
+				return &Post{}, err
+			}
+		}
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		return &Post{}, err
+	}
+	if p.Tags != nil {
+		err = SetPostTags(db, p.ID, p.Tags)
+		if err != nil {
+			return &Post{}, err
+		}
+	}
+	err = p.loadTags(db)
 	if err != nil {
 		return &Post{}, err
 	}
@@ -105,14 +440,258 @@ func (p *Post) UpdatePost(db *gorm.DB) (*Post, error) {
 	return p, nil
 }
 
+// UpdateStatus moves the post to another state, the first time it is made public is kept as its publication date.
+// Public posts with a publishAt after now are scheduled instead, see DuePosts and PublishDuePost
+func (p *Post) UpdateStatus(db *gorm.DB, status string, publishAt *time.Time, now time.Time) (*Post, error) {
+	var err error
+	if !ValidPostStatus(status) {
+		return &Post{}, errors.New("Invalid Status")
+	}
+	p.Status = status
+	p.PublishAt = nil
+	if p.Public() && publishAt != nil && publishAt.After(now) {
+		p.PublishAt = publishAt
+	} else if p.Public() && p.PublishedAt == nil {
+		p.PublishedAt = &now
+	}
+	p.UpdatedAt = now
+	err = db.Debug().Model(&Post{}).Where("id = ?", p.ID).UpdateColumns(map[string]interface{}{
+		"status":       p.Status,
+		"published_at": p.PublishedAt,
+		"publish_at":   p.PublishAt,
+		"updated_at":   p.UpdatedAt,
+	}).Error
+	if err != nil {
+		return &Post{}, err
+	}
+	err = p.loadTags(db)
+	if err != nil {
+		return &Post{}, err
+	}
+	err = db.Debug().Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
+	if err != nil {
+		return &Post{}, err
+	}
+	return p, nil
+}
+
+// DuePosts returns scheduled posts whose time has come, oldest first
+func (p *Post) DuePosts(db *gorm.DB, now time.Time, limit int) (*[]Post, error) {
+	var err error
+	posts := []Post{}
+	err = db.Debug().Model(&Post{}).Where("publish_at is not null and publish_at <= ? and status in (?)", now, []string{PostStatusPublished, PostStatusUnlisted}).
+		Order("publish_at asc").Limit(limit).Find(&posts).Error
+	if err != nil {
+		return &[]Post{}, err
+	}
+	return &posts, nil
+}
+
+// PublishDuePost ends the schedule of a due post. Only one caller wins the conditional update, so with several
+// instances running the scheduler each post is still published once; the losers get false
+func (p *Post) PublishDuePost(db *gorm.DB) (bool, error) {
+	if p.PublishAt == nil {
+		return false, nil
+	}
+	result := db.Debug().Model(&Post{}).Where("id = ? and publish_at is not null", p.ID).UpdateColumns(map[string]interface{}{
+		"published_at": gorm.Expr("coalesce(published_at, publish_at)"),
+		"publish_at":   nil,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return false, nil
+	}
+	if p.PublishedAt == nil {
+		p.PublishedAt = p.PublishAt
+	}
+	p.PublishAt = nil
+	return true, nil
+}
+
+// DeletePost moves the post to the trash, it can be restored until it is purged
 func (p *Post) DeletePost(db *gorm.DB, pid uint64, uid uint32) (int64, error) {
-	db = db.Debug().Model(&Post{}).Where("id = ? and author_id = ?", pid, uid).Take(&Post{}).Delete(&Post{})
+	result := db.Debug().Model(&Post{}).Where("id = ? and author_id = ?", pid, uid).Take(&Post{}).Delete(&Post{})
 
-	if db.Error != nil {
-		if gorm.IsRecordNotFoundError(db.Error) {
+	if result.Error != nil {
+		if gorm.IsRecordNotFoundError(result.Error) {
 			return 0, errors.New("Post not found")
 		}
-		return 0, db.Error
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// FindTrashedPost returns the post if it is in the trash
+func (p *Post) FindTrashedPost(db *gorm.DB, pid uint64) (*Post, error) {
+	var err error
+	err = db.Debug().Unscoped().Model(&Post{}).Where("id = ? and deleted_at is not null", pid).Take(&p).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &Post{}, errors.New("Post not found")
+	}
+	if err != nil {
+		return &Post{}, err
+	}
+	return p, nil
+}
+
+// TrashedPosts lists the posts of the author that are in the trash, most recently deleted first
+func (p *Post) TrashedPosts(db *gorm.DB, uid uint32) (*[]Post, error) {
+	var err error
+	posts := []Post{}
+	err = db.Debug().Unscoped().Model(&Post{}).Where("author_id = ? and deleted_at is not null", uid).Order("deleted_at desc").Limit(100).Find(&posts).Error
+	if err != nil {
+		return &[]Post{}, err
+	}
+	for i := range posts {
+		err = db.Debug().Unscoped().Model(&User{}).Where("id = ?", posts[i].AuthorID).Take(&posts[i].Author).Error
+		if err != nil {
+			return &[]Post{}, err
+		}
+	}
+	return &posts, nil
+}
+
+// RestorePost takes the post out of the trash, which needs its author to still be around
+func (p *Post) RestorePost(db *gorm.DB) (*Post, error) {
+	var err error
+	err = db.Debug().Model(&User{}).Where("id = ?", p.AuthorID).Take(&p.Author).Error
+	if err != nil {
+		return &Post{}, errors.New("Author Not Found")
+	}
+	result := db.Debug().Unscoped().Model(&Post{}).Where("id = ? and deleted_at is not null", p.ID).UpdateColumn("deleted_at", nil)
+	if result.Error != nil {
+		return &Post{}, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return &Post{}, errors.New("Post not found")
+	}
+	p.DeletedAt = nil
+	err = p.loadTags(db)
+	if err != nil {
+		return &Post{}, err
+	}
+	return p, nil
+}
+
+// PurgePost deletes the post for good, with its slugs, tags, revisions, claps, comments and bookmarks
+func PurgePost(db *gorm.DB, pid uint64) error {
+	tx := db.Begin()
+	for _, related := range []interface{}{&PostSlug{}, &PostTag{}, &PostRevision{}, &Clap{}, &Comment{}, &Bookmark{}} {
+		err := tx.Debug().Where("post_id = ?", pid).Delete(related).Error
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	err := tx.Debug().Unscoped().Where("id = ?", pid).Delete(&Post{}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// PurgeDeletedPosts purges the posts that went to the trash before the given time and returns how many
+func PurgeDeletedPosts(db *gorm.DB, before time.Time) (int, error) {
+	var pids []uint64
+	err := db.Debug().Unscoped().Model(&Post{}).Where("deleted_at is not null and deleted_at < ?", before).Pluck("id", &pids).Error
+	if err != nil {
+		return 0, err
+	}
+	for i, pid := range pids {
+		err = PurgePost(db, pid)
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(pids), nil
+}
+
+// postContentColumns are the columns MigrateContent adds to posts, as Post declares them
+type postContentColumns struct {
+	ContentHTML string `gorm:"type:text"`
+}
+
+func (postContentColumns) TableName() string {
+	return "posts"
+}
+
+// MigrateContent widens the content column to hold whole articles and renders the posts saved before content was
+// Markdown. Their content was stored HTML escaped, so it is unescaped first. Like MigrateStatus it runs before
+// AutoMigrate, the one time it finds posts without rendered content
+func MigrateContent(db *gorm.DB) error {
+	if !db.HasTable("posts") || db.Dialect().HasColumn("posts", "content_html") {
+		return nil
+	}
+	tx := db.Begin()
+	err := tx.Debug().AutoMigrate(&postContentColumns{}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	// SQLite does not enforce the column size and cannot alter columns
+	if db.Dialect().GetName() != "sqlite3" {
+		err = tx.Debug().Model(&postContentColumns{}).ModifyColumn("content", "text").Error
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	posts := []struct {
+		ID      uint64
+		Content string
+	}{}
+	err = tx.Debug().Table("posts").Select("id, content").Scan(&posts).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, post := range posts {
+		content := html.UnescapeString(post.Content)
+		err = tx.Debug().Table("posts").Where("id = ?", post.ID).UpdateColumns(map[string]interface{}{
+			"content":      content,
+			"content_html": markdown.Render(content),
+		}).Error
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// postStatusColumns are the columns MigrateStatus adds to posts, as Post declares them
+type postStatusColumns struct {
+	Status      string `gorm:"size:20;not null;default:'draft'"`
+	PublishedAt *time.Time
+}
+
+func (postStatusColumns) TableName() string {
+	return "posts"
+}
+
+// MigrateStatus publishes the posts saved before posts had a status, they were all public then and count as
+// published since they were created. It has to run before AutoMigrate adds the column, and only does anything
+// the one time it finds posts without it
+func MigrateStatus(db *gorm.DB) error {
+	if !db.HasTable("posts") || db.Dialect().HasColumn("posts", "status") {
+		return nil
+	}
+	tx := db.Begin()
+	err := tx.Debug().AutoMigrate(&postStatusColumns{}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Debug().Table("posts").UpdateColumns(map[string]interface{}{
+		"status":       PostStatusPublished,
+		"published_at": gorm.Expr("created_at"),
+	}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
 	}
-	return db.RowsAffected, nil
+	return tx.Commit().Error
 }
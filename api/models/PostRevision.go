@@ -0,0 +1,121 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/mmosoroohh/Go_Medium_API/api/markdown"
+)
+
+// PostRevision is the title and content of a post as one save left them. Revisions are never changed,
+// restoring an old one saves it again as the newest
+type PostRevision struct {
+	ID        uint64    `gorm:"primary_key;auto_increment" json:"id"`
+	PostID    uint64    `gorm:"not null;unique_index:idx_post_revision_number" json:"post_id"`
+	Number    int       `gorm:"not null;unique_index:idx_post_revision_number" json:"number"`
+	Title     string    `gorm:"size:255;not null" json:"title"`
+	Content   string    `gorm:"type:text;not null" json:"content_markdown"`
+	EditorID  uint32    `gorm:"not null" json:"editor_id"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// RevisionRetention decides which revisions are pruned, zero values keep everything. The newest revision is always kept
+type RevisionRetention struct {
+	Keep   int           // How many revisions to keep per post
+	MaxAge time.Duration // How long to keep a revision once a newer one exists
+}
+
+// lockPost holds the post's row until the transaction ends. SQLite has no row locks, it lets one writer at a time
+// in anyway
+func lockPost(tx *gorm.DB, pid uint64) error {
+	if tx.Dialect().GetName() == "sqlite3" {
+		return nil
+	}
+	locked := Post{}
+	return tx.Debug().Set("gorm:query_option", "FOR UPDATE").Model(&Post{}).Where("id = ?", pid).Take(&locked).Error
+}
+
+// addRevision records the post as it is now, numbered after its previous revisions. Callers updating a post lock it
+// first, see lockPost
+func addRevision(db *gorm.DB, p *Post, editorID uint32, createdAt time.Time) error {
+	var last struct{ Number int }
+	err := db.Debug().Model(&PostRevision{}).Select("coalesce(max(number), 0) as number").Where("post_id = ?", p.ID).Scan(&last).Error
+	if err != nil {
+		return err
+	}
+	if editorID == 0 {
+		editorID = p.AuthorID
+	}
+	revision := PostRevision{PostID: p.ID, Number: last.Number + 1, Title: p.Title, Content: p.Content, EditorID: editorID, CreatedAt: createdAt}
+	return db.Debug().Create(&revision).Error
+}
+
+func (r *PostRevision) FindRevisions(db *gorm.DB, pid uint64) (*[]PostRevision, error) {
+	var err error
+	revisions := []PostRevision{}
+	err = db.Debug().Model(&PostRevision{}).Where("post_id = ?", pid).Order("number desc").Find(&revisions).Error
+	if err != nil {
+		return &[]PostRevision{}, err
+	}
+	return &revisions, nil
+}
+
+func (r *PostRevision) FindRevision(db *gorm.DB, pid uint64, number int) (*PostRevision, error) {
+	var err error
+	err = db.Debug().Model(&PostRevision{}).Where("post_id = ? and number = ?", pid, number).Take(&r).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &PostRevision{}, errors.New("Revision Not Found")
+	}
+	if err != nil {
+		return &PostRevision{}, err
+	}
+	return r, nil
+}
+
+// FindPreviousRevision returns the revision before this one that is still kept
+func (r *PostRevision) FindPreviousRevision(db *gorm.DB) (*PostRevision, error) {
+	var err error
+	previous := PostRevision{}
+	err = db.Debug().Model(&PostRevision{}).Where("post_id = ? and number < ?", r.PostID, r.Number).Order("number desc").Take(&previous).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &PostRevision{}, errors.New("Revision Not Found")
+	}
+	if err != nil {
+		return &PostRevision{}, err
+	}
+	return &previous, nil
+}
+
+// RestoreRevision saves the title and content of the revision as the post's current version, the tags are left alone
+func (p *Post) RestoreRevision(db *gorm.DB, r *PostRevision, editorID uint32) (*Post, error) {
+	p.Title = r.Title
+	p.Content = r.Content
+	p.ContentHTML = markdown.Render(r.Content)
+	p.Tags = nil
+	p.EditorID = editorID
+	return p.UpdatePost(db)
+}
+
+// PruneRevisions deletes the revisions of the post the retention policy no longer keeps
+func PruneRevisions(db *gorm.DB, pid uint64, retention RevisionRetention, now time.Time) error {
+	var err error
+	var newest struct{ Number int }
+	err = db.Debug().Model(&PostRevision{}).Select("coalesce(max(number), 0) as number").Where("post_id = ?", pid).Scan(&newest).Error
+	if err != nil {
+		return err
+	}
+	if retention.Keep > 0 {
+		err = db.Debug().Where("post_id = ? and number <= ?", pid, newest.Number-retention.Keep).Delete(&PostRevision{}).Error
+		if err != nil {
+			return err
+		}
+	}
+	if retention.MaxAge > 0 {
+		err = db.Debug().Where("post_id = ? and number < ? and created_at < ?", pid, newest.Number, now.Add(-retention.MaxAge)).Delete(&PostRevision{}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
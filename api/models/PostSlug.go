@@ -0,0 +1,93 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/gosimple/slug"
+	"github.com/jinzhu/gorm"
+)
+
+// maxSlugLength keeps slugs readable, room is left for a collision suffix within the column size
+const maxSlugLength = 100
+
+// PostSlug is a slug a post used to have, kept so that old links redirect to the current one
+type PostSlug struct {
+	ID        uint64    `gorm:"primary_key;auto_increment" json:"id"`
+	PostID    uint64    `gorm:"not null;index" json:"post_id"`
+	Slug      string    `gorm:"size:255;not null;unique" json:"slug"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// MakeSlug turns a title into a lower case, dash separated slug, transliterating non latin scripts
+func MakeSlug(title string) string {
+	s := slug.Make(title)
+	if len(s) > maxSlugLength {
+		s = strings.TrimRight(s[:maxSlugLength], "-")
+	}
+	if s == "" {
+		s = "post"
+	}
+	return s
+}
+
+// UniqueSlug finds a slug for the title that no other post uses, now or as an old slug, by adding -2, -3, ...
+// The title is the one stored, escaped for HTML by Prepare, the slug is made from the title as it was written
+func UniqueSlug(db *gorm.DB, title string, pid uint64) (string, error) {
+	base := MakeSlug(html.UnescapeString(title))
+	candidate := base
+	for n := 2; ; n++ {
+		taken := 0
+		// Posts in the trash keep their slug in case they are restored
+		err := db.Debug().Unscoped().Model(&Post{}).Where("slug = ? and id <> ?", candidate, pid).Count(&taken).Error
+		if err != nil {
+			return "", err
+		}
+		if taken == 0 {
+			err = db.Debug().Model(&PostSlug{}).Where("slug = ? and post_id <> ?", candidate, pid).Count(&taken).Error
+			if err != nil {
+				return "", err
+			}
+		}
+		if taken == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", base, n)
+	}
+}
+
+// FindPostIDByOldSlug returns the post that used to have the slug
+func (ps *PostSlug) FindPostIDByOldSlug(db *gorm.DB, s string) (uint64, error) {
+	var err error
+	err = db.Debug().Model(&PostSlug{}).Where("slug = ?", s).Take(&ps).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return 0, errors.New("Post not found")
+	}
+	if err != nil {
+		return 0, err
+	}
+	return ps.PostID, nil
+}
+
+// BackfillSlugs gives posts created before slugs existed one
+func BackfillSlugs(db *gorm.DB) error {
+	posts := []Post{}
+	err := db.Debug().Unscoped().Model(&Post{}).Where("slug is null or slug = ''").Find(&posts).Error
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		s, err := UniqueSlug(db, posts[i].Title, posts[i].ID)
+		if err != nil {
+			return err
+		}
+		err = db.Debug().Unscoped().Model(&Post{}).Where("id = ?", posts[i].ID).UpdateColumn("slug", s).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,361 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/mmosoroohh/Go_Medium_API/api/utils/cursor"
+)
+
+// DefaultListName is the name of the reading list every user has, bookmarks go there
+const DefaultListName = "Reading list"
+
+// ReadingList is a named list of posts a user saved. Every user has a default one, made when first needed, and
+// may add their own, public ones can be read by anyone
+type ReadingList struct {
+	ID        uint64    `gorm:"primary_key;auto_increment" json:"id"`
+	UserID    uint32    `gorm:"not null;unique_index:idx_reading_list_name" json:"user_id"`
+	Name      string    `gorm:"size:100;not null;unique_index:idx_reading_list_name" json:"name"`
+	Public    bool      `gorm:"not null;default:false" json:"public"`
+	Default   bool      `gorm:"column:is_default;not null;default:false" json:"default"`
+	PostCount int       `gorm:"-" json:"post_count"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// Bookmark is a post saved to a reading list
+type Bookmark struct {
+	ListID    uint64    `gorm:"primary_key;auto_increment:false" json:"list_id"`
+	PostID    uint64    `gorm:"primary_key;auto_increment:false;index" json:"post_id"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	Post      *Post     `gorm:"-" json:"post,omitempty"`
+}
+
+func (l *ReadingList) Prepare() {
+	l.ID = 0
+	l.Name = strings.TrimSpace(l.Name)
+	l.Default = false
+	l.PostCount = 0
+	l.CreatedAt = time.Now()
+	l.UpdatedAt = time.Now()
+}
+
+func (l *ReadingList) Validate() error {
+	if l.Name == "" {
+		return errors.New("Name Required")
+	}
+	if len(l.Name) > 100 {
+		return errors.New("Name Too Long")
+	}
+	return nil
+}
+
+// nameTaken tells whether the user already has another list with the name. The default list's name is always
+// taken, also before the default list is made
+func (l *ReadingList) nameTaken(db *gorm.DB) (bool, error) {
+	if !l.Default && strings.EqualFold(l.Name, DefaultListName) {
+		return true, nil
+	}
+	count := 0
+	err := db.Debug().Model(&ReadingList{}).Where("user_id = ? and name = ? and id <> ?", l.UserID, l.Name, l.ID).Count(&count).Error
+	return count > 0, err
+}
+
+func (l *ReadingList) SaveList(db *gorm.DB) (*ReadingList, error) {
+	taken, err := l.nameTaken(db)
+	if err != nil {
+		return &ReadingList{}, err
+	}
+	if taken {
+		return &ReadingList{}, errors.New("List Already Exists")
+	}
+	err = db.Debug().Create(&l).Error
+	if err != nil {
+		return &ReadingList{}, err
+	}
+	return l, nil
+}
+
+func (l *ReadingList) FindList(db *gorm.DB, lid uint64) (*ReadingList, error) {
+	err := db.Debug().Model(&ReadingList{}).Where("id = ?", lid).Take(&l).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &ReadingList{}, errors.New("List Not Found")
+	}
+	if err != nil {
+		return &ReadingList{}, err
+	}
+	counts, err := listPostCounts(db, []uint64{l.ID}, time.Now())
+	if err != nil {
+		return &ReadingList{}, err
+	}
+	l.PostCount = counts[l.ID]
+	return l, nil
+}
+
+// DefaultList returns the user's reading list, making it on first use
+func (l *ReadingList) DefaultList(db *gorm.DB, uid uint32) (*ReadingList, error) {
+	err := db.Debug().Model(&ReadingList{}).Where("user_id = ? and is_default = ?", uid, true).Take(&l).Error
+	if err == nil {
+		return l, nil
+	}
+	if !gorm.IsRecordNotFoundError(err) {
+		return &ReadingList{}, err
+	}
+	now := time.Now()
+	*l = ReadingList{UserID: uid, Name: DefaultListName, Default: true, CreatedAt: now, UpdatedAt: now}
+	err = db.Debug().Create(&l).Error
+	if err == nil {
+		return l, nil
+	}
+	// Made by a concurrent request in the meantime
+	*l = ReadingList{}
+	findErr := db.Debug().Model(&ReadingList{}).Where("user_id = ? and is_default = ?", uid, true).Take(&l).Error
+	if findErr == nil {
+		return l, nil
+	}
+	// Or the user made a list of their own with the name before it was kept for the default list, that list becomes
+	// it. Bookmarks are private, so it stops being public
+	findErr = db.Debug().Model(&ReadingList{}).Where("user_id = ? and name = ?", uid, DefaultListName).Take(&l).Error
+	if findErr != nil {
+		return &ReadingList{}, err
+	}
+	err = db.Debug().Model(&ReadingList{}).Where("id = ?", l.ID).UpdateColumns(map[string]interface{}{"is_default": true, "public": false}).Error
+	if err != nil {
+		return &ReadingList{}, err
+	}
+	l.Default, l.Public = true, false
+	return l, nil
+}
+
+// UserLists returns the user's lists with how many posts they hold, only the public ones unless all is set
+func (l *ReadingList) UserLists(db *gorm.DB, uid uint32, all bool) (*[]ReadingList, error) {
+	lists := []ReadingList{}
+	query := db.Debug().Model(&ReadingList{}).Where("user_id = ?", uid)
+	if !all {
+		query = query.Where("public = ?", true)
+	}
+	err := query.Order("is_default desc, name").Limit(100).Find(&lists).Error
+	if err != nil {
+		return &[]ReadingList{}, err
+	}
+	lids := make([]uint64, len(lists))
+	for i := range lists {
+		lids[i] = lists[i].ID
+	}
+	counts, err := listPostCounts(db, lids, time.Now())
+	if err != nil {
+		return &[]ReadingList{}, err
+	}
+	for i := range lists {
+		lists[i].PostCount = counts[lists[i].ID]
+	}
+	return &lists, nil
+}
+
+// UpdateList renames the list or changes who can see it, the default list keeps its name
+func (l *ReadingList) UpdateList(db *gorm.DB, name string, public bool) (*ReadingList, error) {
+	name = strings.TrimSpace(name)
+	if l.Default && name != l.Name {
+		return &ReadingList{}, errors.New("Cannot Rename Reading List")
+	}
+	l.Name, l.Public = name, public
+	err := l.Validate()
+	if err != nil {
+		return &ReadingList{}, err
+	}
+	taken, err := l.nameTaken(db)
+	if err != nil {
+		return &ReadingList{}, err
+	}
+	if taken {
+		return &ReadingList{}, errors.New("List Already Exists")
+	}
+	l.UpdatedAt = time.Now()
+	err = db.Debug().Model(&ReadingList{}).Where("id = ?", l.ID).UpdateColumns(map[string]interface{}{
+		"name":       l.Name,
+		"public":     l.Public,
+		"updated_at": l.UpdatedAt,
+	}).Error
+	if err != nil {
+		return &ReadingList{}, err
+	}
+	return l, nil
+}
+
+// DeleteList deletes the list with its bookmarks, the default list stays
+func (l *ReadingList) DeleteList(db *gorm.DB) error {
+	if l.Default {
+		return errors.New("Cannot Delete Reading List")
+	}
+	tx := db.Begin()
+	err := tx.Debug().Where("list_id = ?", l.ID).Delete(&Bookmark{}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Debug().Where("id = ?", l.ID).Delete(&ReadingList{}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// AddPost saves the post to the list
+func (l *ReadingList) AddPost(db *gorm.DB, pid uint64) (*Bookmark, error) {
+	count := 0
+	err := db.Debug().Model(&Bookmark{}).Where("list_id = ? and post_id = ?", l.ID, pid).Count(&count).Error
+	if err != nil {
+		return &Bookmark{}, err
+	}
+	if count > 0 {
+		return &Bookmark{}, errors.New("Already In List")
+	}
+	bookmark := Bookmark{ListID: l.ID, PostID: pid, CreatedAt: time.Now()}
+	err = db.Debug().Create(&bookmark).Error
+	if err != nil {
+		return &Bookmark{}, err
+	}
+	return &bookmark, nil
+}
+
+// RemovePost takes the post off the list
+func (l *ReadingList) RemovePost(db *gorm.DB, pid uint64) error {
+	result := db.Debug().Where("list_id = ? and post_id = ?", l.ID, pid).Delete(&Bookmark{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("Not In List")
+	}
+	return nil
+}
+
+// visibleBookmarks are the bookmarks of posts that can still be read, posts in the trash or taken down drop out
+// of every list and come back if they return
+func visibleBookmarks(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Debug().Table("bookmarks").
+		Joins("join posts on posts.id = bookmarks.post_id and posts.deleted_at is null").
+		Where("posts.status in (?) and (posts.publish_at is null or posts.publish_at <= ?)", []string{PostStatusPublished, PostStatusUnlisted}, now)
+}
+
+// Bookmarks returns a page of the list, latest saved first, each with its post
+func (l *ReadingList) Bookmarks(db *gorm.DB, now time.Time, paging Paging) (*[]Bookmark, PageInfo, error) {
+	err := paging.normalize("created_at")
+	if err != nil {
+		return &[]Bookmark{}, PageInfo{}, err
+	}
+	query := visibleBookmarks(db, now).Where("bookmarks.list_id = ?", l.ID)
+	total := 0
+	err = query.Count(&total).Error
+	if err != nil {
+		return &[]Bookmark{}, PageInfo{}, err
+	}
+	bookmarks := []Bookmark{}
+	err = paging.apply(query.Select("bookmarks.list_id, bookmarks.post_id, bookmarks.created_at"), "bookmarks.created_at", "bookmarks.post_id").
+		Scan(&bookmarks).Error
+	if err != nil {
+		return &[]Bookmark{}, PageInfo{}, err
+	}
+	n, hasNext, hasPrev := paging.keep(len(bookmarks))
+	bookmarks = bookmarks[:n]
+	if paging.backward() {
+		for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+			bookmarks[i], bookmarks[j] = bookmarks[j], bookmarks[i]
+		}
+	}
+	if n == 0 {
+		return &bookmarks, paging.info(total, false, false, nil, nil), nil
+	}
+	pids := make([]uint64, n)
+	for i := range bookmarks {
+		pids[i] = bookmarks[i].PostID
+	}
+	posts := []Post{}
+	err = db.Debug().Model(&Post{}).Where("id in (?)", pids).Find(&posts).Error
+	if err != nil {
+		return &[]Bookmark{}, PageInfo{}, err
+	}
+	err = loadPostDetails(db, posts)
+	if err != nil {
+		return &[]Bookmark{}, PageInfo{}, err
+	}
+	byID := map[uint64]*Post{}
+	for i := range posts {
+		byID[posts[i].ID] = &posts[i]
+	}
+	for i := range bookmarks {
+		bookmarks[i].Post = byID[bookmarks[i].PostID]
+	}
+	first := &cursor.Cursor{Time: bookmarks[0].CreatedAt, ID: bookmarks[0].PostID}
+	last := &cursor.Cursor{Time: bookmarks[n-1].CreatedAt, ID: bookmarks[n-1].PostID}
+	return &bookmarks, paging.info(total, hasNext, hasPrev, first, last), nil
+}
+
+// listPostCounts returns how many readable posts each of the lists holds, by list id
+func listPostCounts(db *gorm.DB, lids []uint64, now time.Time) (map[uint64]int, error) {
+	counts := map[uint64]int{}
+	if len(lids) == 0 {
+		return counts, nil
+	}
+	rows, err := visibleBookmarks(db, now).Select("bookmarks.list_id, count(*)").
+		Where("bookmarks.list_id in (?)", lids).Group("bookmarks.list_id").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var lid uint64
+		var count int
+		err = rows.Scan(&lid, &count)
+		if err != nil {
+			return nil, err
+		}
+		counts[lid] = count
+	}
+	return counts, rows.Err()
+}
+
+// MarkBookmarked sets Bookmarked on the posts in the user's reading list
+func MarkBookmarked(db *gorm.DB, posts []Post, uid uint32) error {
+	if uid == 0 || len(posts) == 0 {
+		return nil
+	}
+	pids := make([]uint64, len(posts))
+	for i := range posts {
+		pids[i] = posts[i].ID
+	}
+	var bookmarked []uint64
+	err := db.Debug().Table("bookmarks").Joins("join reading_lists on reading_lists.id = bookmarks.list_id").
+		Where("reading_lists.user_id = ? and reading_lists.is_default = ? and bookmarks.post_id in (?)", uid, true, pids).
+		Pluck("bookmarks.post_id", &bookmarked).Error
+	if err != nil {
+		return err
+	}
+	set := map[uint64]bool{}
+	for _, pid := range bookmarked {
+		set[pid] = true
+	}
+	for i := range posts {
+		posts[i].Bookmarked = set[posts[i].ID]
+	}
+	return nil
+}
+
+// removeUserLists deletes the user's lists and what they saved in them, for when their account is purged
+func removeUserLists(db *gorm.DB, uid uint32) error {
+	tx := db.Begin()
+	err := tx.Debug().Where("list_id in (?)", tx.Table("reading_lists").Select("id").Where("user_id = ?", uid).SubQuery()).Delete(&Bookmark{}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Debug().Where("user_id = ?", uid).Delete(&ReadingList{}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
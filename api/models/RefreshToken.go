@@ -0,0 +1,79 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type RefreshToken struct {
+	ID        uint64     `gorm:"primary_key;auto_increment" json:"id"`
+	UserID    uint32     `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"size:64;not null;unique" json:"-"`
+	FamilyID  string     `gorm:"size:64;not null;index" json:"family_id"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (rt *RefreshToken) Expired() bool {
+	return time.Now().After(rt.ExpiresAt)
+}
+
+func (rt *RefreshToken) Revoked() bool {
+	return rt.RevokedAt != nil
+}
+
+func (rt *RefreshToken) SaveRefreshToken(db *gorm.DB) (*RefreshToken, error) {
+	var err error
+	err = db.Debug().Model(&RefreshToken{}).Create(&rt).Error
+	if err != nil {
+		return &RefreshToken{}, err
+	}
+	return rt, nil
+}
+
+func (rt *RefreshToken) FindRefreshToken(db *gorm.DB, tokenHash string) (*RefreshToken, error) {
+	var err error
+	err = db.Debug().Model(&RefreshToken{}).Where("token_hash = ?", tokenHash).Take(&rt).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &RefreshToken{}, errors.New("Refresh Token Not Found")
+	}
+	if err != nil {
+		return &RefreshToken{}, err
+	}
+	return rt, nil
+}
+
+// RevokeRefreshToken marks the token as used. It only succeeds for a token that has not been revoked yet,
+// so two concurrent refreshes with the same token can not both win
+func (rt *RefreshToken) RevokeRefreshToken(db *gorm.DB) (int64, error) {
+	now := time.Now()
+	db = db.Debug().Model(&RefreshToken{}).Where("id = ? and revoked_at is null", rt.ID).UpdateColumn("revoked_at", now)
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	if db.RowsAffected == 1 {
+		rt.RevokedAt = &now
+	}
+	return db.RowsAffected, nil
+}
+
+// RevokeTokenFamily revokes every refresh token descending from the same login
+func (rt *RefreshToken) RevokeTokenFamily(db *gorm.DB, familyID string) (int64, error) {
+	db = db.Debug().Model(&RefreshToken{}).Where("family_id = ? and revoked_at is null", familyID).UpdateColumn("revoked_at", time.Now())
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// RevokeUserTokens revokes every outstanding refresh token of a user
+func (rt *RefreshToken) RevokeUserTokens(db *gorm.DB, uid uint32) (int64, error) {
+	db = db.Debug().Model(&RefreshToken{}).Where("user_id = ? and revoked_at is null", uid).UpdateColumn("revoked_at", time.Now())
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
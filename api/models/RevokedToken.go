@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type RevokedToken struct {
+	JTI       string    `gorm:"primary_key;size:64" json:"jti"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// DBRevocationStore keeps the revocation list in the database so it is shared by every instance of the API
+type DBRevocationStore struct {
+	DB *gorm.DB
+}
+
+func NewDBRevocationStore(db *gorm.DB) *DBRevocationStore {
+	return &DBRevocationStore{DB: db}
+}
+
+func (s *DBRevocationStore) Revoke(jti string, expiresAt time.Time) error {
+	revoked := RevokedToken{JTI: jti, ExpiresAt: expiresAt}
+	return s.DB.Debug().Model(&RevokedToken{}).Where(RevokedToken{JTI: jti}).Assign(RevokedToken{ExpiresAt: expiresAt}).FirstOrCreate(&revoked).Error
+}
+
+func (s *DBRevocationStore) IsRevoked(jti string) (bool, error) {
+	var count int
+	err := s.DB.Debug().Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *DBRevocationStore) Purge(now time.Time) (int64, error) {
+	db := s.DB.Debug().Where("expires_at < ?", now).Delete(&RevokedToken{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
@@ -0,0 +1,497 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/mmosoroohh/Go_Medium_API/api/utils/search"
+)
+
+// What a search hit can be
+const (
+	SearchKindPost = "post"
+	SearchKindUser = "user"
+)
+
+// How many words of a post's content are shown around the matches
+const snippetWords = 35
+
+// Titles and usernames are stored escaped for HTML, they are searched and highlighted as they were written
+var (
+	postSearchTitle = sqlUnescape("title")
+	userSearchName  = sqlUnescape("username")
+)
+
+// Posts are searched by their title and content, matches in the title count for more. The Postgres index is built
+// on these very expressions, queries have to use them as they are to make use of it
+var (
+	postSearchVector = fmt.Sprintf("setweight(to_tsvector('english', %s), 'A') || setweight(to_tsvector('english', content), 'B')", postSearchTitle)
+	userSearchVector = fmt.Sprintf("to_tsvector('simple', %s)", userSearchName)
+)
+
+// sqlUnescape undoes html.EscapeString on a column, &amp; last so that escaped entities stay as they were written
+func sqlUnescape(column string) string {
+	for _, entity := range [][2]string{{"&lt;", "<"}, {"&gt;", ">"}, {"&#39;", "''"}, {"&#34;", `"`}, {"&amp;", "&"}} {
+		column = fmt.Sprintf("replace(%s, '%s', '%s')", column, entity[0], entity[1])
+	}
+	return column
+}
+
+// SearchQuery is what to search for. Posts only turn up if anyone may read them
+type SearchQuery struct {
+	Text  string
+	Kinds []string  // Only hits of these kinds, both posts and users if empty
+	Now   time.Time // Posts scheduled to go live after this time are left out
+}
+
+// SearchHit is a post or user matching a search. Title is the post's title or the user's name and Snippet an
+// excerpt of a post's content, both escaped for HTML with the matches wrapped in <mark>
+type SearchHit struct {
+	Kind    string      `json:"type"`
+	ID      uint64      `json:"id"`
+	Rank    float64     `json:"rank"`
+	Title   string      `json:"title"`
+	Snippet string      `json:"snippet"`
+	Post    *Post       `json:"post,omitempty"`
+	User    *PublicUser `json:"user,omitempty"`
+}
+
+// MarshalJSON only shows the public profile of a post's author, anyone may search
+func (h SearchHit) MarshalJSON() ([]byte, error) {
+	type hit SearchHit
+	type post struct {
+		*Post
+		Author PublicUser `json:"author"`
+	}
+	aux := struct {
+		hit
+		Post *post `json:"post,omitempty"`
+	}{hit: hit(h)}
+	if h.Post != nil {
+		aux.Post = &post{Post: h.Post, Author: PublicUser{ID: h.Post.Author.ID, Username: h.Post.Author.Username}}
+	}
+	return json.Marshal(aux)
+}
+
+// SearchEngine finds the posts and users matching a query, best matches first. It returns limit hits starting at
+// offset and how many there are in all. Engines may leave Title and Snippet to be highlighted by Search
+type SearchEngine interface {
+	Search(query SearchQuery, offset, limit int) ([]SearchHit, int, error)
+}
+
+// NewSearchEngine picks the full-text search of the database, or an index kept in memory for databases without one
+func NewSearchEngine(db *gorm.DB) SearchEngine {
+	switch db.Dialect().GetName() {
+	case "postgres":
+		return &PostgresSearch{DB: db}
+	case "mysql":
+		return NewMySQLSearch(db)
+	}
+	return NewIndexSearch(db)
+}
+
+// MigrateSearch creates the full-text indexes searches need, where the database has them
+func MigrateSearch(db *gorm.DB) error {
+	switch db.Dialect().GetName() {
+	case "postgres":
+		// The first indexes were built on the escaped titles and usernames, queries no longer use them
+		err := db.Exec("drop index if exists idx_posts_search, idx_users_search").Error
+		if err != nil {
+			return err
+		}
+		err = db.Exec(fmt.Sprintf("create index if not exists idx_posts_text_search on posts using gin ((%s))", postSearchVector)).Error
+		if err != nil {
+			return err
+		}
+		return db.Exec(fmt.Sprintf("create index if not exists idx_users_text_search on users using gin ((%s))", userSearchVector)).Error
+	case "mysql":
+		err := addFulltextIndex(db, "posts", "idx_posts_search", "title, content")
+		if err != nil {
+			return err
+		}
+		return addFulltextIndex(db, "users", "idx_users_search", "username")
+	}
+	return nil
+}
+
+// MySQL cannot add an index only if it does not exist yet, so it is looked up first
+func addFulltextIndex(db *gorm.DB, table, name, columns string) error {
+	var count int
+	err := db.Raw("select count(*) from information_schema.statistics where table_schema = database() and table_name = ? and index_name = ?", table, name).Row().Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+	return db.Exec(fmt.Sprintf("alter table %s add fulltext index %s (%s)", table, name, columns)).Error
+}
+
+// Search returns a page of the hits for the query along with the posts and users they are, ranked by relevance. The
+// page's cursors count positions, the ranking has no value to continue from
+func Search(db *gorm.DB, engine SearchEngine, query SearchQuery, paging Paging) (*[]SearchHit, PageInfo, error) {
+	err := paging.normalize("rank")
+	if err != nil {
+		return &[]SearchHit{}, PageInfo{}, err
+	}
+	for _, kind := range query.Kinds {
+		if kind != SearchKindPost && kind != SearchKindUser {
+			return &[]SearchHit{}, PageInfo{}, errors.New("Invalid Type")
+		}
+	}
+	if len(search.Terms(query.Text)) == 0 {
+		return &[]SearchHit{}, PageInfo{}, nil
+	}
+	hits, total, err := engine.Search(query, paging.offset(), paging.Limit)
+	if err != nil {
+		return &[]SearchHit{}, PageInfo{}, err
+	}
+
+	pids, uids := []uint64{}, []uint64{}
+	for _, hit := range hits {
+		if hit.Kind == SearchKindPost {
+			pids = append(pids, hit.ID)
+		} else {
+			uids = append(uids, hit.ID)
+		}
+	}
+	posts, users := []Post{}, []PublicUser{}
+	if len(pids) > 0 {
+		err = db.Debug().Model(&Post{}).Where("id in (?)", pids).Find(&posts).Error
+		if err != nil {
+			return &[]SearchHit{}, PageInfo{}, err
+		}
+		err = loadPostDetails(db, posts)
+		if err != nil {
+			return &[]SearchHit{}, PageInfo{}, err
+		}
+	}
+	if len(uids) > 0 {
+		err = db.Debug().Model(&User{}).Select("id, username").Where("id in (?)", uids).Scan(&users).Error
+		if err != nil {
+			return &[]SearchHit{}, PageInfo{}, err
+		}
+	}
+	postsByID, usersByID := map[uint64]*Post{}, map[uint64]*PublicUser{}
+	for i := range posts {
+		postsByID[posts[i].ID] = &posts[i]
+	}
+	for i := range users {
+		usersByID[uint64(users[i].ID)] = &users[i]
+	}
+
+	// Whatever went away since it was found is left out
+	found := []SearchHit{}
+	for _, hit := range hits {
+		hit.Post, hit.User = postsByID[hit.ID], usersByID[hit.ID]
+		if hit.Kind == SearchKindPost {
+			hit.User = nil
+			if hit.Post == nil {
+				continue
+			}
+			if hit.Title == "" {
+				hit.Title = search.Highlight(html.UnescapeString(hit.Post.Title), query.Text, 0)
+				hit.Snippet = search.Highlight(hit.Post.Content, query.Text, snippetWords)
+			}
+		} else {
+			hit.Post = nil
+			if hit.User == nil {
+				continue
+			}
+			if hit.Title == "" {
+				hit.Title = search.Highlight(html.UnescapeString(hit.User.Username), query.Text, 0)
+			}
+		}
+		found = append(found, hit)
+	}
+	return &found, paging.offsetInfo(total, len(found)), nil
+}
+
+// searchKinds tells whether the query asks for hits of the kind
+func (q *SearchQuery) searchKinds(kind string) bool {
+	if len(q.Kinds) == 0 {
+		return true
+	}
+	for _, k := range q.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// searchRow is a hit as the databases return it
+type searchRow struct {
+	Kind    string
+	ID      uint64
+	Score   float64
+	Title   string
+	Snippet string
+}
+
+// unionSearch runs a select per kind of hit, all of them giving kind, id, score, title and snippet, and returns
+// the page of their best rows
+func unionSearch(db *gorm.DB, selects []string, args []interface{}, offset, limit int) ([]SearchHit, int, error) {
+	if len(selects) == 0 {
+		return []SearchHit{}, 0, nil
+	}
+	union := strings.Join(selects, " union all ")
+	var total int
+	err := db.Raw("select count(*) from ("+union+") hits", args...).Row().Scan(&total)
+	if err != nil {
+		return []SearchHit{}, 0, err
+	}
+	rows := []searchRow{}
+	err = db.Raw("select * from ("+union+") hits order by score desc, kind, id limit ? offset ?", append(args, limit, offset)...).Scan(&rows).Error
+	if err != nil {
+		return []SearchHit{}, 0, err
+	}
+	hits := make([]SearchHit, len(rows))
+	for i, row := range rows {
+		hits[i] = SearchHit{Kind: row.Kind, ID: row.ID, Rank: row.Score, Title: row.Title, Snippet: row.Snippet}
+	}
+	return hits, total, nil
+}
+
+// PostgresSearch searches with the text search of Postgres, ranked by ts_rank and highlighted by ts_headline
+type PostgresSearch struct {
+	DB *gorm.DB
+}
+
+func (s *PostgresSearch) Search(query SearchQuery, offset, limit int) ([]SearchHit, int, error) {
+	selects, args := []string{}, []interface{}{}
+	if query.searchKinds(SearchKindPost) {
+		selects = append(selects, fmt.Sprintf("select '%s' as kind, id, ts_rank(%s, q) as score, '' as title, '' as snippet "+
+			"from posts, plainto_tsquery('english', ?) q where %s @@ q and deleted_at is null and status = ? "+
+			"and (publish_at is null or publish_at <= ?)", SearchKindPost, postSearchVector, postSearchVector))
+		args = append(args, query.Text, PostStatusPublished, query.Now)
+	}
+	if query.searchKinds(SearchKindUser) {
+		selects = append(selects, fmt.Sprintf("select '%s' as kind, id, ts_rank(%s, q) as score, '' as title, '' as snippet "+
+			"from users, plainto_tsquery('simple', ?) q where %s @@ q and deleted_at is null", SearchKindUser, userSearchVector, userSearchVector))
+		args = append(args, query.Text)
+	}
+	hits, total, err := unionSearch(s.DB, selects, args, offset, limit)
+	if err != nil {
+		return hits, total, err
+	}
+	err = s.headlines(query, hits)
+	return hits, total, err
+}
+
+// headlines highlights the page's hits, ts_headline goes over the whole content so it is left until the page is known
+func (s *PostgresSearch) headlines(query SearchQuery, hits []SearchHit) error {
+	pids, uids := []uint64{}, []uint64{}
+	for _, hit := range hits {
+		if hit.Kind == SearchKindPost {
+			pids = append(pids, hit.ID)
+		} else {
+			uids = append(uids, hit.ID)
+		}
+	}
+	mark := fmt.Sprintf(`StartSel="%s", StopSel="%s"`, search.MarkStart, search.MarkStop)
+	rows := []searchRow{}
+	if len(pids) > 0 {
+		err := s.DB.Raw(fmt.Sprintf("select '%s' as kind, id, ts_headline('english', %s, q, ?) as title, ts_headline('english', content, q, ?) as snippet "+
+			"from posts, plainto_tsquery('english', ?) q where id in (?)", SearchKindPost, postSearchTitle),
+			mark+", HighlightAll=true", fmt.Sprintf("%s, MaxWords=%d, MinWords=%d", mark, snippetWords, snippetWords/2), query.Text, pids).Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+	}
+	if len(uids) > 0 {
+		users := []searchRow{}
+		err := s.DB.Raw(fmt.Sprintf("select '%s' as kind, id, ts_headline('simple', %s, q, ?) as title, '' as snippet "+
+			"from users, plainto_tsquery('simple', ?) q where id in (?)", SearchKindUser, userSearchName),
+			mark+", HighlightAll=true", query.Text, uids).Scan(&users).Error
+		if err != nil {
+			return err
+		}
+		rows = append(rows, users...)
+	}
+	found := map[string]searchRow{}
+	for _, row := range rows {
+		found[fmt.Sprintf("%s:%d", row.Kind, row.ID)] = row
+	}
+	for i := range hits {
+		row := found[fmt.Sprintf("%s:%d", hits[i].Kind, hits[i].ID)]
+		hits[i].Title, hits[i].Snippet = search.Mark(row.Title), search.Mark(row.Snippet)
+	}
+	return nil
+}
+
+// MySQLSearch searches with the FULLTEXT indexes of MySQL, every term has to match, as a word or the start of one.
+// FULLTEXT leaves out words shorter than MinTokenSize and its own stopwords, such terms are matched with LIKE instead
+type MySQLSearch struct {
+	DB           *gorm.DB
+	MinTokenSize int // The server's innodb_ft_min_token_size
+}
+
+// mysqlStopwords are the words of InnoDB's default stopword list that search.Terms keeps
+var mysqlStopwords = map[string]bool{
+	"about": true, "com": true, "de": true, "en": true, "from": true, "how": true, "la": true, "und": true,
+	"what": true, "when": true, "where": true, "who": true, "www": true,
+}
+
+func NewMySQLSearch(db *gorm.DB) *MySQLSearch {
+	s := &MySQLSearch{DB: db, MinTokenSize: 3}
+	var size int
+	err := db.Raw("select @@innodb_ft_min_token_size").Row().Scan(&size)
+	if err == nil && size > 0 {
+		s.MinTokenSize = size
+	}
+	return s
+}
+
+func (s *MySQLSearch) Search(query SearchQuery, offset, limit int) ([]SearchHit, int, error) {
+	// The terms are letters and digits only, so they cannot carry operators or wildcards of their own
+	words, short := []string{}, []string{}
+	for _, term := range search.Terms(query.Text) {
+		if len(term) < s.MinTokenSize || mysqlStopwords[term] {
+			short = append(short, term)
+		} else {
+			words = append(words, "+"+term+"*")
+		}
+	}
+	against := strings.Join(words, " ")
+
+	// The title and snippet are highlighted by Search
+	selects, args := []string{}, []interface{}{}
+	if query.searchKinds(SearchKindPost) {
+		score, scoreArgs, where, whereArgs := mysqlMatch(against, short, "title", "content")
+		selects = append(selects, fmt.Sprintf("select '%s' as kind, id, %s as score, '' as title, '' as snippet from posts "+
+			"where %s and deleted_at is null and status = ? and (publish_at is null or publish_at <= ?)", SearchKindPost, score, where))
+		args = append(append(append(args, scoreArgs...), whereArgs...), PostStatusPublished, query.Now)
+	}
+	if query.searchKinds(SearchKindUser) {
+		score, scoreArgs, where, whereArgs := mysqlMatch(against, short, "username")
+		selects = append(selects, fmt.Sprintf("select '%s' as kind, id, %s as score, '' as title, '' as snippet from users "+
+			"where %s and deleted_at is null", SearchKindUser, score, where))
+		args = append(append(args, scoreArgs...), whereArgs...)
+	}
+	return unionSearch(s.DB, selects, args, offset, limit)
+}
+
+// mysqlMatch is the score and condition of the columns holding every term. Terms FULLTEXT leaves out are looked for
+// at the start of a word with LIKE, and score one for each column they turn up in
+func mysqlMatch(against string, short []string, columns ...string) (score string, scoreArgs []interface{}, where string, whereArgs []interface{}) {
+	scores, conditions := []string{}, []string{}
+	if against != "" {
+		match := fmt.Sprintf("match (%s) against (? in boolean mode)", strings.Join(columns, ", "))
+		scores = append(scores, match)
+		scoreArgs = append(scoreArgs, against)
+		conditions = append(conditions, match)
+		whereArgs = append(whereArgs, against)
+	}
+	for _, term := range short {
+		pattern := "% " + term + "%"
+		for _, column := range columns {
+			scores = append(scores, fmt.Sprintf("(concat(' ', %s) like ?)", column))
+			scoreArgs = append(scoreArgs, pattern)
+		}
+		conditions = append(conditions, fmt.Sprintf("concat(' ', concat_ws(' ', %s)) like ?", strings.Join(columns, ", ")))
+		whereArgs = append(whereArgs, pattern)
+	}
+	return strings.Join(scores, " + "), scoreArgs, strings.Join(conditions, " and "), whereArgs
+}
+
+// IndexSearch searches an index kept in memory, for databases without full-text search such as SQLite. The index
+// is rebuilt whenever posts or users changed since it was built, so it suits small sites and tests
+type IndexSearch struct {
+	DB *gorm.DB
+
+	mu    sync.Mutex
+	index *search.Index
+	stamp string // What the posts and users looked like when the index was built
+}
+
+func NewIndexSearch(db *gorm.DB) *IndexSearch {
+	return &IndexSearch{DB: db, index: search.NewIndex()}
+}
+
+func (s *IndexSearch) Search(query SearchQuery, offset, limit int) ([]SearchHit, int, error) {
+	index, err := s.refresh()
+	if err != nil {
+		return []SearchHit{}, 0, err
+	}
+	matches := index.Search(query.Text, query.Kinds...)
+
+	// The index holds every live post, which of them may be read is up to the database
+	pids := []uint64{}
+	for _, match := range matches {
+		if match.Key.Kind == SearchKindPost {
+			pids = append(pids, match.Key.ID)
+		}
+	}
+	readable := map[uint64]bool{}
+	if len(pids) > 0 {
+		ids := []uint64{}
+		err = s.DB.Debug().Model(&Post{}).Where("id in (?)", pids).Where("status = ?", PostStatusPublished).
+			Where("publish_at is null or publish_at <= ?", query.Now).Pluck("id", &ids).Error
+		if err != nil {
+			return []SearchHit{}, 0, err
+		}
+		for _, id := range ids {
+			readable[id] = true
+		}
+	}
+	hits := []SearchHit{}
+	for _, match := range matches {
+		if match.Key.Kind == SearchKindPost && !readable[match.Key.ID] {
+			continue
+		}
+		hits = append(hits, SearchHit{Kind: match.Key.Kind, ID: match.Key.ID, Rank: match.Score})
+	}
+	total := len(hits)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return hits[offset:end], total, nil
+}
+
+// refresh rebuilds the index if posts or users were added, changed or deleted since it was built
+func (s *IndexSearch) refresh() (*search.Index, error) {
+	stamp := ""
+	for _, table := range []string{"posts", "users"} {
+		var count int
+		var updated sql.NullString
+		err := s.DB.Table(table).Select("count(*), max(updated_at)").Where("deleted_at is null").Row().Scan(&count, &updated)
+		if err != nil {
+			return nil, err
+		}
+		stamp += fmt.Sprintf("%s:%d:%s;", table, count, updated.String)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stamp == s.stamp {
+		return s.index, nil
+	}
+
+	index := search.NewIndex()
+	posts := []Post{}
+	err := s.DB.Debug().Model(&Post{}).Select("id, title, content").Find(&posts).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		index.Add(search.Key{Kind: SearchKindPost, ID: post.ID}, search.Field{Text: html.UnescapeString(post.Title), Weight: 2}, search.Field{Text: post.Content, Weight: 1})
+	}
+	users := []User{}
+	err = s.DB.Debug().Model(&User{}).Select("id, username").Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		index.Add(search.Key{Kind: SearchKindUser, ID: uint64(user.ID)}, search.Field{Text: html.UnescapeString(user.Username), Weight: 1})
+	}
+	s.index, s.stamp = index, stamp
+	return index, nil
+}
@@ -0,0 +1,193 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gosimple/slug"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	MaxTagsPerPost = 5
+	maxTagLength   = 30
+)
+
+type Tag struct {
+	ID        uint64    `gorm:"primary_key;auto_increment" json:"id"`
+	Name      string    `gorm:"size:50;not null;unique" json:"name"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// PostTag links posts and tags
+type PostTag struct {
+	PostID uint64 `gorm:"primary_key;auto_increment:false"`
+	TagID  uint64 `gorm:"primary_key;auto_increment:false;index"`
+}
+
+// TagCount is a tag with the number of posts anyone can see under it
+type TagCount struct {
+	Name  string `json:"name"`
+	Posts int    `json:"posts"`
+}
+
+// NormalizeTag turns user input into the form tags are stored in: lower case, dash separated and transliterated,
+// so that "Go Lang" and "go-lang" are the same tag
+func NormalizeTag(name string) string {
+	s := slug.Make(name)
+	if len(s) > maxTagLength {
+		s = strings.TrimRight(s[:maxTagLength], "-")
+	}
+	return s
+}
+
+// NormalizeTags normalizes and dedupes the tags, keeping their order. Nil stays nil, meaning "not given"
+func NormalizeTags(names []string) []string {
+	if names == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, name := range names {
+		tag := NormalizeTag(name)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// SetPostTags replaces the tags of the post, creating tags that do not exist yet
+func SetPostTags(db *gorm.DB, pid uint64, names []string) error {
+	tx := db.Begin()
+	err := tx.Debug().Where("post_id = ?", pid).Delete(&PostTag{}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, name := range names {
+		tag := Tag{}
+		err = tx.Debug().Where(Tag{Name: name}).Attrs(Tag{CreatedAt: time.Now()}).FirstOrCreate(&tag).Error
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		err = tx.Debug().Create(&PostTag{PostID: pid, TagID: tag.ID}).Error
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// PostTagNames returns the tags of each of the posts, by post id
+func PostTagNames(db *gorm.DB, pids []uint64) (map[uint64][]string, error) {
+	tags := map[uint64][]string{}
+	if len(pids) == 0 {
+		return tags, nil
+	}
+	rows, err := db.Debug().Table("post_tags").Select("post_tags.post_id, tags.name").
+		Joins("join tags on tags.id = post_tags.tag_id").Where("post_tags.post_id in (?)", pids).
+		Order("tags.name").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pid uint64
+		var name string
+		err = rows.Scan(&pid, &name)
+		if err != nil {
+			return nil, err
+		}
+		tags[pid] = append(tags[pid], name)
+	}
+	return tags, rows.Err()
+}
+
+// TagCounts lists the tags in use with how many posts visible at the given time carry them, most used first
+func TagCounts(db *gorm.DB, now time.Time) (*[]TagCount, error) {
+	counts := []TagCount{}
+	err := db.Debug().Table("tags").Select("tags.name, count(posts.id) as posts").
+		Joins("join post_tags on post_tags.tag_id = tags.id").
+		Joins("join posts on posts.id = post_tags.post_id").
+		Where("posts.deleted_at is null and posts.status = ? and (posts.publish_at is null or posts.publish_at <= ?)", PostStatusPublished, now).
+		Group("tags.name").Order("posts desc, tags.name").Scan(&counts).Error
+	if err != nil {
+		return &[]TagCount{}, err
+	}
+	return &counts, nil
+}
+
+func (t *Tag) FindTagByName(db *gorm.DB, name string) (*Tag, error) {
+	var err error
+	err = db.Debug().Model(&Tag{}).Where("name = ?", name).Take(&t).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &Tag{}, errors.New("Tag Not Found")
+	}
+	if err != nil {
+		return &Tag{}, err
+	}
+	return t, nil
+}
+
+// RenameTag gives the tag a new name, which must not be taken by another tag; merge into that one instead
+func (t *Tag) RenameTag(db *gorm.DB, name string) (*Tag, error) {
+	var err error
+	name = NormalizeTag(name)
+	if name == "" {
+		return &Tag{}, errors.New("Name Required")
+	}
+	taken := 0
+	err = db.Debug().Model(&Tag{}).Where("name = ? and id <> ?", name, t.ID).Count(&taken).Error
+	if err != nil {
+		return &Tag{}, err
+	}
+	if taken > 0 {
+		return &Tag{}, errors.New("Tag Already Exists")
+	}
+	err = db.Debug().Model(&Tag{}).Where("id = ?", t.ID).UpdateColumn("name", name).Error
+	if err != nil {
+		return &Tag{}, err
+	}
+	t.Name = name
+	return t, nil
+}
+
+// MergeTag moves all posts of the tag over to the target tag and deletes it
+func (t *Tag) MergeTag(db *gorm.DB, target *Tag) error {
+	if t.ID == target.ID {
+		return errors.New("Cannot Merge A Tag Into Itself")
+	}
+	tx := db.Begin()
+	// Posts that already carry both tags would end up with the target twice. MySQL cannot delete from a table it
+	// selects from in the same statement, so the posts are looked up first
+	pids := []uint64{}
+	err := tx.Debug().Model(&PostTag{}).Where("tag_id = ?", target.ID).Pluck("post_id", &pids).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if len(pids) > 0 {
+		err = tx.Debug().Where("tag_id = ? and post_id in (?)", t.ID, pids).Delete(&PostTag{}).Error
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	err = tx.Debug().Model(&PostTag{}).Where("tag_id = ?", t.ID).UpdateColumn("tag_id", target.ID).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Debug().Where("id = ?", t.ID).Delete(&Tag{}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
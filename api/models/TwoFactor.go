@@ -0,0 +1,134 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type TwoFactor struct {
+	UserID       uint32     `gorm:"primary_key;auto_increment:false" json:"user_id"`
+	Secret       string     `gorm:"size:64;not null" json:"-"`
+	LastUsedStep int64      `gorm:"not null;default:0" json:"-"`
+	ConfirmedAt  *time.Time `json:"confirmed_at"`
+	CreatedAt    time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+type RecoveryCode struct {
+	ID        uint64     `gorm:"primary_key;auto_increment" json:"id"`
+	UserID    uint32     `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;not null;unique" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+func (tf *TwoFactor) Enabled() bool {
+	return tf.ConfirmedAt != nil
+}
+
+func (tf *TwoFactor) FindTwoFactor(db *gorm.DB, uid uint32) (*TwoFactor, error) {
+	var err error
+	err = db.Debug().Model(&TwoFactor{}).Where("user_id = ?", uid).Take(&tf).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &TwoFactor{}, errors.New("Two Factor Not Enrolled")
+	}
+	if err != nil {
+		return &TwoFactor{}, err
+	}
+	return tf, nil
+}
+
+// TwoFactorEnabled reports whether the user has a confirmed second factor
+func (tf *TwoFactor) TwoFactorEnabled(db *gorm.DB, uid uint32) (bool, error) {
+	var count int
+	err := db.Debug().Model(&TwoFactor{}).Where("user_id = ? and confirmed_at is not null", uid).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// SaveTwoFactor starts a new, unconfirmed, enrolment replacing any earlier unconfirmed one
+func (tf *TwoFactor) SaveTwoFactor(db *gorm.DB) (*TwoFactor, error) {
+	var err error
+	err = db.Debug().Where("user_id = ? and confirmed_at is null", tf.UserID).Delete(&TwoFactor{}).Error
+	if err != nil {
+		return &TwoFactor{}, err
+	}
+	tf.ConfirmedAt = nil
+	tf.LastUsedStep = 0
+	err = db.Debug().Model(&TwoFactor{}).Create(&tf).Error
+	if err != nil {
+		return &TwoFactor{}, err
+	}
+	return tf, nil
+}
+
+// UseStep records the time step of an accepted code, failing if that step (or a later one) was already used
+func (tf *TwoFactor) UseStep(db *gorm.DB, step int64) error {
+	db = db.Debug().Model(&TwoFactor{}).Where("user_id = ? and last_used_step < ?", tf.UserID, step).UpdateColumns(
+		map[string]interface{}{
+			"last_used_step": step,
+			"updated_at":     time.Now(),
+		},
+	)
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected != 1 {
+		return errors.New("Code Already Used")
+	}
+	tf.LastUsedStep = step
+	return nil
+}
+
+func (tf *TwoFactor) ConfirmTwoFactor(db *gorm.DB) error {
+	now := time.Now()
+	err := db.Debug().Model(&TwoFactor{}).Where("user_id = ?", tf.UserID).UpdateColumn("confirmed_at", now).Error
+	if err != nil {
+		return err
+	}
+	tf.ConfirmedAt = &now
+	return nil
+}
+
+// DeleteTwoFactor disables the second factor and drops the user's recovery codes
+func (tf *TwoFactor) DeleteTwoFactor(db *gorm.DB, uid uint32) error {
+	err := db.Debug().Where("user_id = ?", uid).Delete(&RecoveryCode{}).Error
+	if err != nil {
+		return err
+	}
+	return db.Debug().Where("user_id = ?", uid).Delete(&TwoFactor{}).Error
+}
+
+// ReplaceRecoveryCodes drops the user's recovery codes and stores the new hashes
+func (rc *RecoveryCode) ReplaceRecoveryCodes(db *gorm.DB, uid uint32, codeHashes []string) error {
+	tx := db.Begin()
+	err := tx.Debug().Where("user_id = ?", uid).Delete(&RecoveryCode{}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, hash := range codeHashes {
+		err = tx.Debug().Model(&RecoveryCode{}).Create(&RecoveryCode{UserID: uid, CodeHash: hash}).Error
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// UseRecoveryCode consumes one of the user's recovery codes
+func (rc *RecoveryCode) UseRecoveryCode(db *gorm.DB, uid uint32, codeHash string) error {
+	db = db.Debug().Model(&RecoveryCode{}).Where("user_id = ? and code_hash = ? and used_at is null", uid, codeHash).UpdateColumn("used_at", time.Now())
+	if db.Error != nil {
+		return db.Error
+	}
+	if db.RowsAffected != 1 {
+		return errors.New("Invalid Recovery Code")
+	}
+	return nil
+}
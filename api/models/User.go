@@ -4,6 +4,8 @@ import (
 	"errors"
 	"github.com/badoux/checkmail"
 	"github.com/jinzhu/gorm"
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/utils/cursor"
 	"golang.org/x/crypto/bcrypt"
 	"html"
 	"log"
@@ -16,8 +18,25 @@ type User struct {
 	Username  string    `gorm:"size:255;not null;unique" json:"username"`
 	Email     string    `gorm:"size:100;not null;unique" json:"email"`
 	Password  string    `gorm:"size:100;not null;" json:"password"`
+	Role      string    `gorm:"size:20;not null;default:'author'" json:"role"`
 	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	DeletedAt       *time.Time `gorm:"index" json:"deleted_at"` // Set while the account is in the trash, gorm leaves those out of every query
+}
+
+// PublicUser is what anyone may see of a user, without their email, password or anything else about the account
+type PublicUser struct {
+	ID       uint32 `json:"id"`
+	Username string `json:"username"`
+}
+
+// UserFilter narrows down AllUsers, zero values match everything
+type UserFilter struct {
+	Role          string
+	CreatedAfter  time.Time // Only users who signed up at or after this time
+	CreatedBefore time.Time // Only users who signed up before this time
 }
 
 func Hash(password string) ([]byte, error) {
@@ -28,6 +47,10 @@ func VerifyPassword(hashedPassword, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 }
 
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
 func (u *User) BeforeSave() error {
 	hashedPassword, err := Hash(u.Password)
 	if err != nil {
@@ -41,6 +64,8 @@ func (u *User) Prepare() {
 	u.ID = 0
 	u.Username = html.EscapeString(strings.TrimSpace(u.Username))
 	u.Email = html.EscapeString(strings.TrimSpace(u.Email))
+	u.Role = "" // Roles are only ever granted through UpdateRole
+	u.EmailVerifiedAt = nil
 	u.CreatedAt = time.Now()
 	u.UpdatedAt = time.Now()
 }
@@ -95,6 +120,9 @@ func (u *User) Validate(action string) error {
 func (u *User) SaveUser(db *gorm.DB) (*User, error) {
 
 	var err error
+	if u.Role == "" {
+		u.Role = auth.DefaultRole
+	}
 	err = db.Debug().Create(&u).Error
 	if err != nil {
 		return &User{}, err
@@ -102,14 +130,55 @@ func (u *User) SaveUser(db *gorm.DB) (*User, error) {
 	return u, nil
 }
 
-func (u *User) AllUsers(db *gorm.DB) (*[]User, error) {
-	var err error
+// AllUsers returns a page of the users that match the filter, see Paging
+func (u *User) AllUsers(db *gorm.DB, filter UserFilter, paging Paging) (*[]User, PageInfo, error) {
+	err := paging.normalize("created_at", UserSorts...)
+	if err != nil {
+		return &[]User{}, PageInfo{}, err
+	}
+	query := db.Debug().Model(&User{})
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if !filter.CreatedAfter.IsZero() {
+		query = query.Where("created_at >= ?", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		query = query.Where("created_at < ?", filter.CreatedBefore)
+	}
+	total := 0
+	err = query.Count(&total).Error
+	if err != nil {
+		return &[]User{}, PageInfo{}, err
+	}
 	users := []User{}
-	err = db.Debug().Model(&User{}).Limit(100).Find(&users).Error
+	err = paging.apply(query, "users."+paging.Sort, "users.id").Find(&users).Error
 	if err != nil {
-		return &[]User{}, err
+		return &[]User{}, PageInfo{}, err
 	}
-	return &users, err
+	n, hasNext, hasPrev := paging.keep(len(users))
+	users = users[:n]
+	if paging.backward() {
+		for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+	var first, last *cursor.Cursor
+	if n > 0 {
+		first, last = users[0].pageKey(paging.Sort), users[n-1].pageKey(paging.Sort)
+	}
+	return &users, paging.info(total, hasNext, hasPrev, first, last), nil
+}
+
+// pageKey is where the user sits in a listing sorted by the column
+func (u *User) pageKey(sort string) *cursor.Cursor {
+	switch sort {
+	case "username":
+		return &cursor.Cursor{Text: u.Username, ID: uint64(u.ID)}
+	case "updated_at":
+		return &cursor.Cursor{Time: u.UpdatedAt, ID: uint64(u.ID)}
+	}
+	return &cursor.Cursor{Time: u.CreatedAt, ID: uint64(u.ID)}
 }
 
 func (u *User) SingleUser(db *gorm.DB, uid uint32) (*User, error) {
@@ -133,10 +202,10 @@ func (u *User) UpdateAUser(db *gorm.DB, uid uint32) (*User, error) {
 	}
 	db = db.Debug().Model(&User{}).Where("id = ?", uid).Take(&User{}).UpdateColumn(
 		map[string]interface{}{
-			"password":  u.Password,
-			"username":  u.Username,
-			"email":     u.Email,
-			"update_at": time.Now(),
+			"password":   u.Password,
+			"username":   u.Username,
+			"email":      u.Email,
+			"updated_at": time.Now(),
 		},
 	)
 	if db.Error != nil {
@@ -150,10 +219,200 @@ func (u *User) UpdateAUser(db *gorm.DB, uid uint32) (*User, error) {
 	return u, nil
 }
 
-func (u *User) DeleteUser(db *gorm.DB, uid uint32) (int64, error) {
-	db = db.Debug().Model(&User{}).Where("id = ?", uid).Take(&User{}).Delete(&User{})
+// UpdatePassword hashes and stores a new password, going through the same BeforeSave hashing as UpdateAUser
+func (u *User) UpdatePassword(db *gorm.DB, uid uint32, password string) error {
+	u.Password = password
+	err := u.BeforeSave()
+	if err != nil {
+		return err
+	}
+	db = db.Debug().Model(&User{}).Where("id = ?", uid).Take(&User{}).UpdateColumns(
+		map[string]interface{}{
+			"password":   u.Password,
+			"updated_at": time.Now(),
+		},
+	)
+	return db.Error
+}
+
+// ClearEmailVerification marks the user's email as unverified and expires the verification tokens still pending,
+// they were sent to an address the user no longer has
+func (u *User) ClearEmailVerification(db *gorm.DB) (*User, error) {
+	now := time.Now()
+	tx := db.Begin()
+	err := tx.Debug().Model(&User{}).Where("id = ?", u.ID).UpdateColumn("email_verified_at", gorm.Expr("NULL")).Error
+	if err != nil {
+		tx.Rollback()
+		return &User{}, err
+	}
+	err = tx.Debug().Model(&EmailVerification{}).Where("user_id = ? and used_at is null and expires_at > ?", u.ID, now).UpdateColumn("expires_at", now).Error
+	if err != nil {
+		tx.Rollback()
+		return &User{}, err
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		return &User{}, err
+	}
+	u.EmailVerifiedAt = nil
+	return u, nil
+}
+
+func (u *User) UpdateRole(db *gorm.DB, uid uint32, role string) (*User, error) {
+	if !auth.ValidRole(role) {
+		return &User{}, errors.New("Invalid Role")
+	}
+	db = db.Debug().Model(&User{}).Where("id = ?", uid).Take(&User{}).UpdateColumns(
+		map[string]interface{}{
+			"role":       role,
+			"updated_at": time.Now(),
+		},
+	)
 	if db.Error != nil {
-		return 0, db.Error
+		return &User{}, db.Error
+	}
+	err := db.Debug().Model(&User{}).Where("id = ?", uid).Take(&u).Error
+	if err != nil {
+		return &User{}, err
 	}
-	return db.RowsAffected, nil
+	return u, nil
+}
+
+// DeleteUser moves the user and their posts to the trash, restoring the user brings those posts back
+func (u *User) DeleteUser(db *gorm.DB, uid uint32) (int64, error) {
+	err := db.Debug().Model(&User{}).Where("id = ?", uid).Take(&User{}).Error
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	tx := db.Begin()
+	err = tx.Debug().Model(&Post{}).Where("author_id = ?", uid).UpdateColumn("deleted_at", now).Error
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	result := tx.Debug().Model(&User{}).Where("id = ?", uid).UpdateColumn("deleted_at", now)
+	if result.Error != nil {
+		tx.Rollback()
+		return 0, result.Error
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected, nil
+}
+
+// FindTrashedUser returns the user if their account is in the trash
+func (u *User) FindTrashedUser(db *gorm.DB, uid uint32) (*User, error) {
+	var err error
+	err = db.Debug().Unscoped().Model(&User{}).Where("id = ? and deleted_at is not null", uid).Take(&u).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return &User{}, errors.New("User Not Found")
+	}
+	if err != nil {
+		return &User{}, err
+	}
+	return u, nil
+}
+
+// RestoreUser takes the user out of the trash along with the posts that went with them.
+// Posts they had deleted themselves before stay in the trash
+func (u *User) RestoreUser(db *gorm.DB) (*User, error) {
+	if u.DeletedAt == nil {
+		return &User{}, errors.New("User Not Found")
+	}
+	tx := db.Begin()
+	err := tx.Debug().Unscoped().Model(&Post{}).Where("author_id = ? and deleted_at >= ?", u.ID, *u.DeletedAt).UpdateColumn("deleted_at", nil).Error
+	if err != nil {
+		tx.Rollback()
+		return &User{}, err
+	}
+	err = tx.Debug().Unscoped().Model(&User{}).Where("id = ?", u.ID).UpdateColumn("deleted_at", nil).Error
+	if err != nil {
+		tx.Rollback()
+		return &User{}, err
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		return &User{}, err
+	}
+	u.DeletedAt = nil
+	return u, nil
+}
+
+// PurgeUser deletes the user for good, with their posts and everything their account holds
+func PurgeUser(db *gorm.DB, uid uint32) error {
+	var pids []uint64
+	err := db.Debug().Unscoped().Model(&Post{}).Where("author_id = ?", uid).Pluck("id", &pids).Error
+	if err != nil {
+		return err
+	}
+	for _, pid := range pids {
+		err = PurgePost(db, pid)
+		if err != nil {
+			return err
+		}
+	}
+	err = removeUserClaps(db, uid)
+	if err != nil {
+		return err
+	}
+	err = deleteUserComments(db, uid)
+	if err != nil {
+		return err
+	}
+	err = removeUserFollows(db, uid)
+	if err != nil {
+		return err
+	}
+	err = removeUserLists(db, uid)
+	if err != nil {
+		return err
+	}
+	tx := db.Begin()
+	for _, related := range []interface{}{&RefreshToken{}, &PasswordReset{}, &EmailVerification{}, &TwoFactor{}, &RecoveryCode{}, &APIKey{}, &Identity{}} {
+		err = tx.Debug().Where("user_id = ?", uid).Delete(related).Error
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	err = tx.Debug().Unscoped().Where("id = ?", uid).Delete(&User{}).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// PurgeDeletedUsers purges the users that went to the trash before the given time and returns how many
+func PurgeDeletedUsers(db *gorm.DB, before time.Time) (int, error) {
+	var uids []uint32
+	err := db.Debug().Unscoped().Model(&User{}).Where("deleted_at is not null and deleted_at < ?", before).Pluck("id", &uids).Error
+	if err != nil {
+		return 0, err
+	}
+	for i, uid := range uids {
+		err = PurgeUser(db, uid)
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(uids), nil
+}
+
+// DBAccountStore tells the auth package which accounts still exist, users in the trash or purged are not active
+type DBAccountStore struct {
+	DB *gorm.DB
+}
+
+func NewDBAccountStore(db *gorm.DB) *DBAccountStore {
+	return &DBAccountStore{DB: db}
+}
+
+func (s *DBAccountStore) AccountActive(uid uint32) (bool, error) {
+	count := 0
+	err := s.DB.Debug().Model(&User{}).Where("id = ?", uid).Count(&count).Error
+	return count > 0, err
 }
@@ -0,0 +1,116 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Identity is the user as asserted by the ID token of a provider
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider signs users in through the authorization code flow with PKCE against an OpenID Connect issuer
+type Provider struct {
+	Name     string
+	config   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewProvider reads the discovery document of the issuer, which also gives us the keys to validate ID tokens with
+func NewProvider(ctx context.Context, name, issuer, clientID, clientSecret, redirectURL string) (*Provider, error) {
+	provider, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{
+		Name: name,
+		config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: clientID}),
+	}, nil
+}
+
+// ProvidersFromEnv sets up every provider listed in OAUTH_PROVIDERS (e.g "google,gitlab"), each configured through
+// OAUTH_<NAME>_ISSUER, OAUTH_<NAME>_CLIENT_ID, OAUTH_<NAME>_CLIENT_SECRET and optionally OAUTH_<NAME>_REDIRECT_URL,
+// which defaults to APP_URL/auth/<name>/callback
+func ProvidersFromEnv(ctx context.Context) (map[string]*Provider, error) {
+	providers := map[string]*Provider{}
+	for _, name := range strings.Split(os.Getenv("OAUTH_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OAUTH_" + strings.ToUpper(name) + "_"
+		issuer := os.Getenv(prefix + "ISSUER")
+		if issuer == "" {
+			return nil, fmt.Errorf("%sISSUER is not set", prefix)
+		}
+		redirectURL := os.Getenv(prefix + "REDIRECT_URL")
+		if redirectURL == "" {
+			redirectURL = fmt.Sprintf("%s/auth/%s/callback", strings.TrimRight(os.Getenv("APP_URL"), "/"), name)
+		}
+		provider, err := NewProvider(ctx, name, issuer, os.Getenv(prefix+"CLIENT_ID"), os.Getenv(prefix+"CLIENT_SECRET"), redirectURL)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		providers[name] = provider
+	}
+	return providers, nil
+}
+
+// AuthCodeURL is where to send the user, the nonce ends up in the ID token and the verifier is needed for the exchange
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) string {
+	return p.config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange redeems the code for an ID token and validates it, including that it was issued for our nonce
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	token, err := p.config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("No ID token in the token response")
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("Invalid nonce")
+	}
+	claims := struct {
+		Email         string      `json:"email"`
+		EmailVerified interface{} `json:"email_verified"`
+		Name          string      `json:"name"`
+	}{}
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{
+		Provider: p.Name,
+		Subject:  idToken.Subject,
+		Email:    strings.ToLower(claims.Email),
+		// Some providers send the flag as a string
+		EmailVerified: claims.EmailVerified == true || claims.EmailVerified == "true",
+		Name:          claims.Name,
+	}, nil
+}
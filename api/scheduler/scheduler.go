@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+)
+
+// batchSize limits how many due posts one run picks up, the rest follow on the next tick
+const batchSize = 100
+
+// Scheduler publishes scheduled posts once they are due and purges the trash. Every instance of the API may run one,
+// PublishDuePost makes sure each post is only published (and announced) once
+type Scheduler struct {
+	DB       *gorm.DB
+	Interval time.Duration
+	Now      func() time.Time // The clock, tests replace it to travel in time
+
+	// OnPublish runs the side effects of a post going live, once per post
+	OnPublish func(post *models.Post)
+
+	// PurgeAfter is how long deleted posts and users stay in the trash, zero keeps them forever
+	PurgeAfter time.Duration
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+func New(db *gorm.DB, interval time.Duration) *Scheduler {
+	return &Scheduler{DB: db, Interval: interval, Now: time.Now}
+}
+
+// RunOnce publishes the posts that are due now and returns how many this instance published
+func (s *Scheduler) RunOnce() (int, error) {
+	post := models.Post{}
+	posts, err := post.DuePosts(s.DB, s.Now(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+	published := 0
+	for i := range *posts {
+		due := &(*posts)[i]
+		won, err := due.PublishDuePost(s.DB)
+		if err != nil {
+			return published, err
+		}
+		if !won {
+			continue
+		}
+		published++
+		if s.OnPublish != nil {
+			s.OnPublish(due)
+		}
+	}
+	return published, nil
+}
+
+// Purge deletes the posts and users that have been in the trash for longer than PurgeAfter for good
+func (s *Scheduler) Purge() (int, int, error) {
+	if s.PurgeAfter <= 0 {
+		return 0, 0, nil
+	}
+	before := s.Now().Add(-s.PurgeAfter)
+	// Users first, their posts go with them
+	users, err := models.PurgeDeletedUsers(s.DB, before)
+	if err != nil {
+		return 0, users, err
+	}
+	posts, err := models.PurgeDeletedPosts(s.DB, before)
+	return posts, users, err
+}
+
+// Start runs the scheduler in the background until Stop is called
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+	s.stop, s.done = make(chan struct{}), make(chan struct{})
+	go s.loop(s.stop, s.done)
+}
+
+// Stop asks the scheduler to stop and waits for a run in progress to finish
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop, s.done = nil, nil
+}
+
+func (s *Scheduler) loop(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		published, err := s.RunOnce()
+		if err != nil {
+			log.Printf("Publishing scheduled posts failed: %v", err)
+		} else if published > 0 {
+			log.Printf("Published %d scheduled posts", published)
+		}
+		posts, users, err := s.Purge()
+		if err != nil {
+			log.Printf("Purging the trash failed: %v", err)
+		} else if posts > 0 || users > 0 {
+			log.Printf("Purged %d posts and %d users from the trash", posts, users)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
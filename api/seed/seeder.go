@@ -4,6 +4,7 @@ import (
 	"github.com/jinzhu/gorm"
 	"github.com/mmosoroohh/Go_Medium_API/api/models"
 	"log"
+	"time"
 )
 
 var users = []models.User{
@@ -11,6 +12,7 @@ var users = []models.User{
 		Username: "mmosoroohh",
 		Email:    "arnoldosoro@gmail.com",
 		Password: "Password",
+		Role:     "admin",
 	},
 	models.User{
 		Username: "lutherjunior",
@@ -41,26 +43,31 @@ var posts = []models.Post{
 
 func Load(db *gorm.DB) {
 
-	err := db.Debug().DropTableIfExists(&models.Post{}, &models.User{}).Error
+	err := db.Debug().DropTableIfExists(&models.Bookmark{}, &models.ReadingList{}, &models.Follow{}, &models.Comment{}, &models.Clap{}, &models.PostRevision{}, &models.PostTag{}, &models.Tag{}, &models.PostSlug{}, &models.Identity{}, &models.APIKey{}, &models.RecoveryCode{}, &models.TwoFactor{}, &models.EmailVerification{}, &models.PasswordReset{}, &models.RevokedToken{}, &models.RefreshToken{}, &models.Post{}, &models.User{}).Error
 	if err != nil {
 		log.Fatalf("Can't drop table: %v", err)
 	}
-	err = db.Debug().AutoMigrate(&models.User{}, &models.Post{}).Error
+	err = db.Debug().AutoMigrate(&models.User{}, &models.Post{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.PasswordReset{}, &models.EmailVerification{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.APIKey{}, &models.Identity{}, &models.PostSlug{}, &models.Tag{}, &models.PostTag{}, &models.PostRevision{}, &models.Clap{}, &models.Comment{}, &models.Follow{}, &models.ReadingList{}, &models.Bookmark{}).Error
 	if err != nil {
 		log.Fatalf("Can't migrate table: %v", err)
 	}
 
-	err = db.Debug().Model(&models.Post{}).AddForeignKey("author_id", "users(id)", "cascade", "cascade").Error
+	// Users are only ever deleted by the purge, which takes their posts out first
+	err = db.Debug().Model(&models.Post{}).AddForeignKey("author_id", "users(id)", "restrict", "cascade").Error
 	if err != nil {
 		log.Fatalf("attaching foreign key error: %v", err)
 	}
 
+	now := time.Now()
 	for i, _ := range users {
+		users[i].EmailVerifiedAt = &now
 		err = db.Debug().Model(&models.User{}).Create(&users[i]).Error
 		if err != nil {
 			log.Fatalf("Can't seed users table: %v", err)
 		}
 		posts[i].AuthorID = users[i].ID
+		posts[i].Status = models.PostStatusPublished
+		posts[i].PublishedAt = &now
 
 		err = db.Debug().Model(&models.Post{}).Create(&posts[i]).Error
 		if err != nil {
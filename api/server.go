@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"github.com/joho/godotenv"
 	"github.com/mmosoroohh/Go_Medium_API/api/controllers"
+	"github.com/mmosoroohh/Go_Medium_API/api/scheduler"
 	"github.com/mmosoroohh/Go_Medium_API/api/seed"
 	"log"
+	"time"
 )
 
 var server = controllers.Server{}
@@ -23,5 +25,12 @@ func Run() {
 
 	seed.Load(server.DB)
 
+	// Scheduled posts are published and the trash is purged in the background, stopping it waits for a run in progress
+	publisher := scheduler.New(server.DB, time.Minute)
+	publisher.OnPublish = server.PostPublished
+	publisher.PurgeAfter = server.TrashRetention
+	publisher.Start()
+	defer publisher.Stop()
+
 	server.Run(":8080")
 }
@@ -0,0 +1,46 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Cursor marks where a page of a listing begins or ends: the sort value of the item there and its id to break
+// ties. It carries the sort it was made for, so following it keeps the order of the listing
+type Cursor struct {
+	Sort string    `json:"s,omitempty"`
+	Desc bool      `json:"d,omitempty"`
+	Time time.Time `json:"t"`           // The sort value when sorting by a time
+	Text string    `json:"x,omitempty"` // The sort value when sorting by text
+	ID   uint64    `json:"i"`
+	Prev bool      `json:"p,omitempty"` // Leads to the page before the item instead of the one after it
+
+	// Offset is how many items come before the page, for listings ranked by relevance which have no sort value
+	// to continue from
+	Offset int `json:"o,omitempty"`
+}
+
+// Encode turns the cursor into the opaque token handed to clients
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// Decode reads a token made by Encode, an empty token is the start of the listing and gives a nil cursor
+func Decode(token string) (*Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errors.New("Invalid Cursor")
+	}
+	c := Cursor{}
+	err = json.Unmarshal(raw, &c)
+	if err != nil {
+		return nil, errors.New("Invalid Cursor")
+	}
+	return &c, nil
+}
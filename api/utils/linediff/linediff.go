@@ -0,0 +1,53 @@
+package linediff
+
+import (
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const (
+	OpEqual  = "equal"
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// Line is one line of a diff, Op tells whether it is in both texts, only the new or only the old one
+type Line struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Diff compares the texts line by line, the way a reader would look at two versions of an article
+func Diff(from, to string) []Line {
+	// Otherwise a last line without a line break differs from the same line followed by more
+	from, to = terminate(from), terminate(to)
+	dmp := diffmatchpatch.New()
+	fromRunes, toRunes, lineArray := dmp.DiffLinesToRunes(from, to)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMainRunes(fromRunes, toRunes, false), lineArray)
+
+	lines := []Line{}
+	for _, d := range diffs {
+		op := OpEqual
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			op = OpInsert
+		case diffmatchpatch.DiffDelete:
+			op = OpDelete
+		}
+		for _, text := range strings.SplitAfter(d.Text, "\n") {
+			if text == "" {
+				continue
+			}
+			lines = append(lines, Line{Op: op, Text: strings.TrimSuffix(text, "\n")})
+		}
+	}
+	return lines
+}
+
+func terminate(text string) string {
+	if text != "" && !strings.HasSuffix(text, "\n") {
+		return text + "\n"
+	}
+	return text
+}
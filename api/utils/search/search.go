@@ -0,0 +1,297 @@
+// Package search is a small full-text index kept in memory, for databases that cannot search text themselves.
+// It also marks the matches in a text, for showing hits to readers
+package search
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// MarkStart and MarkStop surround the matches in text marked elsewhere, e.g. by the database, see Mark. They are
+// private use characters so they cannot be confused with the text
+const (
+	MarkStart = "\uE000"
+	MarkStop  = "\uE001"
+)
+
+// Okapi BM25 parameters, the usual ones
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true, "by": true,
+	"for": true, "if": true, "in": true, "into": true, "is": true, "it": true, "no": true, "not": true, "of": true,
+	"on": true, "or": true, "s": true, "such": true, "t": true, "that": true, "the": true, "their": true,
+	"then": true, "there": true, "these": true, "they": true, "this": true, "to": true, "was": true, "will": true,
+	"with": true,
+}
+
+// Key names a document in the index, Kind tells apart documents from different tables
+type Key struct {
+	Kind string
+	ID   uint64
+}
+
+// Field is a piece of the text of a document, terms in a field with a higher weight count for more
+type Field struct {
+	Text   string
+	Weight float64
+}
+
+// Match is a document that has every term of a query, the higher the score the better it matches
+type Match struct {
+	Key   Key
+	Score float64
+}
+
+type document struct {
+	terms  map[string]float64 // Weighted number of times each term occurs
+	length float64
+}
+
+// Index finds documents by the terms in them and ranks them with BM25. It is safe for concurrent use
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[Key]*document
+	postings map[string]map[Key]bool
+	length   float64 // Sum of the lengths of all documents
+}
+
+func NewIndex() *Index {
+	return &Index{docs: map[Key]*document{}, postings: map[string]map[Key]bool{}}
+}
+
+// Add indexes a document, replacing what was indexed under its key before
+func (ix *Index) Add(key Key, fields ...Field) {
+	doc := &document{terms: map[string]float64{}}
+	for _, field := range fields {
+		weight := field.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		for _, term := range Terms(field.Text) {
+			doc.terms[term] += weight
+			doc.length += weight
+		}
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(key)
+	ix.docs[key] = doc
+	ix.length += doc.length
+	for term := range doc.terms {
+		if ix.postings[term] == nil {
+			ix.postings[term] = map[Key]bool{}
+		}
+		ix.postings[term][key] = true
+	}
+}
+
+// Remove takes a document out of the index
+func (ix *Index) Remove(key Key) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(key)
+}
+
+func (ix *Index) remove(key Key) {
+	doc, ok := ix.docs[key]
+	if !ok {
+		return
+	}
+	for term := range doc.terms {
+		delete(ix.postings[term], key)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	ix.length -= doc.length
+	delete(ix.docs, key)
+}
+
+// Len is the number of documents in the index
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Search finds the documents of the kinds, all of them if none are given, that have every term of the query. The
+// best matches come first, equally good ones by kind and id
+func (ix *Index) Search(query string, kinds ...string) []Match {
+	terms := Terms(query)
+	matches := []Match{}
+	if len(terms) == 0 {
+		return matches
+	}
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	// Walk the rarest term's documents, the others only have to be looked up
+	sort.Slice(terms, func(i, j int) bool { return len(ix.postings[terms[i]]) < len(ix.postings[terms[j]]) })
+	n := float64(len(ix.docs))
+	average := ix.length / n
+	for key := range ix.postings[terms[0]] {
+		if len(kinds) > 0 && !contains(kinds, key.Kind) {
+			continue
+		}
+		doc := ix.docs[key]
+		score := 0.0
+		for _, term := range terms {
+			tf, ok := doc.terms[term]
+			if !ok {
+				score = -1
+				break
+			}
+			df := float64(len(ix.postings[term]))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			score += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*doc.length/average))
+		}
+		if score >= 0 {
+			matches = append(matches, Match{Key: key, Score: score})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if matches[i].Key.Kind != matches[j].Key.Kind {
+			return matches[i].Key.Kind < matches[j].Key.Kind
+		}
+		return matches[i].Key.ID < matches[j].Key.ID
+	})
+	return matches
+}
+
+// Terms breaks a text into the terms it is indexed by: lower case words without the most common English ones, with
+// plurals folded into the singular. Every term appears once, in the order of the text
+func Terms(text string) []string {
+	seen := map[string]bool{}
+	terms := []string{}
+	for _, word := range strings.FieldsFunc(text, separator) {
+		term := normalize(word)
+		if term == "" || seen[term] {
+			continue
+		}
+		seen[term] = true
+		terms = append(terms, term)
+	}
+	return terms
+}
+
+// Highlight escapes the text for HTML and wraps the words matching the query's terms in <mark>. With a positive
+// words it only keeps an excerpt of about that many words, starting shortly before the first match
+func Highlight(text, query string, words int) string {
+	terms := map[string]bool{}
+	for _, term := range Terms(query) {
+		terms[term] = true
+	}
+	text = strings.Join(strings.Fields(text), " ")
+
+	// The byte offsets of the words, and whether they match
+	type span struct {
+		start, end int
+		match      bool
+	}
+	spans := []span{}
+	start := -1
+	for i, r := range text + " " {
+		if separator(r) {
+			if start >= 0 {
+				spans = append(spans, span{start, i, terms[normalize(text[start:i])]})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+
+	from, to := 0, len(spans)
+	if words > 0 && len(spans) > words {
+		for i, s := range spans {
+			if s.match {
+				from = i - words/5
+				break
+			}
+		}
+		if from < 0 {
+			from = 0
+		}
+		if from+words > len(spans) {
+			from = len(spans) - words
+		}
+		to = from + words
+	}
+
+	if len(spans) == 0 {
+		return html.EscapeString(text)
+	}
+	var out strings.Builder
+	begin, end := 0, len(text)
+	if from > 0 {
+		out.WriteString("… ")
+		begin = spans[from].start
+	}
+	if to < len(spans) {
+		end = spans[to-1].end
+	}
+	pos := begin
+	for _, s := range spans[from:to] {
+		out.WriteString(html.EscapeString(text[pos:s.start]))
+		if s.match {
+			out.WriteString("<mark>" + html.EscapeString(text[s.start:s.end]) + "</mark>")
+		} else {
+			out.WriteString(html.EscapeString(text[s.start:s.end]))
+		}
+		pos = s.end
+	}
+	out.WriteString(html.EscapeString(text[pos:end]))
+	if to < len(spans) {
+		out.WriteString(" …")
+	}
+	return out.String()
+}
+
+// Mark escapes text whose matches are surrounded by MarkStart and MarkStop for HTML, and wraps the matches in <mark>
+func Mark(text string) string {
+	text = html.EscapeString(text)
+	text = strings.Replace(text, MarkStart, "<mark>", -1)
+	return strings.Replace(text, MarkStop, "</mark>", -1)
+}
+
+func separator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// normalize turns a word into its term, or "" for words that are not worth indexing
+func normalize(word string) string {
+	term := strings.ToLower(word)
+	if stopWords[term] {
+		return ""
+	}
+	switch {
+	case len(term) > 4 && strings.HasSuffix(term, "ies"):
+		term = term[:len(term)-3] + "y"
+	case strings.HasSuffix(term, "sses"):
+		term = term[:len(term)-2]
+	case len(term) > 3 && strings.HasSuffix(term, "s") && !strings.HasSuffix(term, "ss") &&
+		!strings.HasSuffix(term, "us") && !strings.HasSuffix(term, "is"):
+		term = term[:len(term)-1]
+	}
+	return term
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
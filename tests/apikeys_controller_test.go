@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/middlewares"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func TestAPIKeys(t *testing.T) {
+
+	auth.SetAPIKeyStore(models.NewDBAPIKeyStore(server.DB))
+	defer auth.SetAPIKeyStore(nil)
+
+	err := refreshUserTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	person, err := seedUser()
+	if err != nil {
+		log.Fatalf("Error Occurred seeding user %v\n", err)
+	}
+	tokens, err := server.SignIn(person.Email, "Password")
+	if err != nil {
+		log.Fatalf("Error occurred login: %v\n", err)
+	}
+	tokenString := fmt.Sprintf("Bearer %v", tokens.AccessToken)
+
+	samples := []struct {
+		inputJSON    string
+		statusCode   int
+		errorMessage string
+	}{
+		{inputJSON: `{"name": "", "scopes": ["posts:write"]}`, statusCode: 422, errorMessage: "Name Required"},
+		{inputJSON: `{"name": "ci", "scopes": []}`, statusCode: 422, errorMessage: "Scopes Required"},
+		{inputJSON: `{"name": "ci", "scopes": ["everything"]}`, statusCode: 422, errorMessage: "Invalid Scope"},
+		{inputJSON: `{"name": "ci", "scopes": ["posts:write"], "expires_at": "2000-01-01T00:00:00Z"}`, statusCode: 422, errorMessage: "Expiry Must Be In The Future"},
+		{inputJSON: `{"name": "ci", "scopes": ["posts:write"]}`, statusCode: 201},
+	}
+
+	var key string
+	var keyID float64
+	for _, v := range samples {
+		req, err := http.NewRequest("POST", "/api-keys", bytes.NewBufferString(v.inputJSON))
+		if err != nil {
+			t.Errorf("Error Occurred: %v\n", err)
+		}
+		req.Header.Set("Authorization", tokenString)
+		rec := httptest.NewRecorder()
+		http.HandlerFunc(server.CreateAPIKey).ServeHTTP(rec, req)
+
+		responseMap := make(map[string]interface{})
+		err = json.Unmarshal([]byte(rec.Body.String()), &responseMap)
+		if err != nil {
+			t.Errorf("Error occurred converting to json: %v", err)
+		}
+		assert.Equal(t, rec.Code, v.statusCode)
+		if v.statusCode == 201 {
+			key = responseMap["key"].(string)
+			keyID = responseMap["id"].(float64)
+		} else {
+			assert.Equal(t, responseMap["error"], v.errorMessage)
+		}
+	}
+
+	apiKeyRequest := func(handler http.HandlerFunc) int {
+		req, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Errorf("Error Occurred: %v\n", err)
+		}
+		req.Header.Set("Authorization", "ApiKey "+key)
+		rec := httptest.NewRecorder()
+		middlewares.SetMiddlewareAuthentication(handler).ServeHTTP(rec, req)
+		return rec.Code
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		uid, err := auth.ExtractTokenID(r)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, uid, person.ID)
+		w.WriteHeader(http.StatusOK)
+	}
+
+	assert.Equal(t, apiKeyRequest(middlewares.RequireScope(ok, auth.ScopePostsWrite)), http.StatusOK)
+	assert.Equal(t, apiKeyRequest(middlewares.RequireScope(ok, auth.ScopeUsersWrite)), http.StatusForbidden)
+	assert.Equal(t, apiKeyRequest(middlewares.RequireSession(ok)), http.StatusForbidden)
+
+	// Listing shows the key, with its last use, but never the key itself
+	req, err := http.NewRequest("GET", "/api-keys", nil)
+	if err != nil {
+		t.Errorf("Error Occurred: %v\n", err)
+	}
+	req.Header.Set("Authorization", tokenString)
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(server.GetAPIKeys).ServeHTTP(rec, req)
+	keys := []map[string]interface{}{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &keys)
+	if err != nil {
+		t.Errorf("Error occurred converting to json: %v", err)
+	}
+	assert.Equal(t, len(keys), 1)
+	assert.NotEqual(t, keys[0]["last_used_at"], nil)
+	assert.Equal(t, keys[0]["key"], nil)
+
+	// Revoked keys stop working
+	req, err = http.NewRequest("DELETE", "/api-keys", nil)
+	if err != nil {
+		t.Errorf("Error Occurred: %v\n", err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"id": strconv.Itoa(int(keyID))})
+	req.Header.Set("Authorization", tokenString)
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(server.DeleteAPIKey).ServeHTTP(rec, req)
+	assert.Equal(t, rec.Code, http.StatusNoContent)
+
+	assert.Equal(t, apiKeyRequest(middlewares.RequireScope(ok, auth.ScopePostsWrite)), http.StatusUnauthorized)
+}
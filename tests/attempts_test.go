@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func TestMemoryAttemptTracker(t *testing.T) {
+	policy := auth.ThrottlePolicy{
+		FreeAttempts: 2,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Second * 8,
+		MaxAttempts:  6,
+		Lockout:      time.Minute,
+		Window:       time.Minute * 10,
+	}
+	tracker := auth.NewMemoryAttemptTracker(policy)
+	now := time.Now()
+
+	samples := []struct {
+		wait time.Duration
+	}{
+		{wait: 0},               // 1 failure
+		{wait: 0},               // 2 failures
+		{wait: time.Second},     // 3 failures, backoff starts
+		{wait: time.Second * 2}, // 4 failures
+		{wait: time.Second * 4}, // 5 failures
+		{wait: time.Minute},     // 6 failures, locked out
+	}
+	for _, v := range samples {
+		tracker.Failure("account:a@gmail.com", now)
+		assert.Equal(t, tracker.Wait("account:a@gmail.com", now), v.wait)
+	}
+	assert.Equal(t, tracker.Wait("account:a@gmail.com", now.Add(time.Second*30)), time.Second*30)
+	assert.Equal(t, tracker.Wait("account:a@gmail.com", now.Add(time.Minute)), time.Duration(0))
+	assert.Equal(t, tracker.Wait("account:b@gmail.com", now), time.Duration(0))
+
+	tracker.Reset("account:a@gmail.com")
+	assert.Equal(t, tracker.Wait("account:a@gmail.com", now), time.Duration(0))
+
+	// Attempts reserved at the same time count against each other, only the free ones get through
+	allowed := make(chan bool, 20)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			allowed <- tracker.Reserve("account:c@gmail.com", now) == 0
+		}()
+	}
+	wg.Wait()
+	close(allowed)
+	count := 0
+	for ok := range allowed {
+		if ok {
+			count++
+		}
+	}
+	assert.Equal(t, count, policy.FreeAttempts+1)
+
+	// Attempts that did not fail are taken back
+	tracker.Release("account:c@gmail.com")
+	assert.Equal(t, tracker.Wait("account:c@gmail.com", now), time.Duration(0))
+}
+
+func TestLoginLockout(t *testing.T) {
+
+	policy := auth.DefaultThrottlePolicy()
+	policy.FreeAttempts, policy.MaxAttempts = 0, 2
+	server.LoginAttempts = auth.NewMemoryAttemptTracker(policy)
+	server.LoginIPAttempts = auth.NewMemoryAttemptTracker(auth.DefaultThrottlePolicy())
+	defer func() {
+		server.LoginAttempts = auth.NewMemoryAttemptTracker(auth.DefaultThrottlePolicy())
+	}()
+
+	err := refreshUserTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	person, err := seedUser()
+	if err != nil {
+		log.Fatalf("Error Occurred seeding user %v\n", err)
+	}
+
+	login := func(password string) *httptest.ResponseRecorder {
+		inputJSON := fmt.Sprintf(`{"email": "%s", "password": "%s"}`, person.Email, password)
+		req, err := http.NewRequest("POST", "/login", bytes.NewBufferString(inputJSON))
+		if err != nil {
+			t.Errorf("Error Occurred: %v\n", err)
+		}
+		rec := httptest.NewRecorder()
+		http.HandlerFunc(server.Login).ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, login("wrong password").Code, http.StatusUnprocessableEntity)
+
+	// Backing off, even the right password has to wait
+	rec := login("Password")
+	assert.Equal(t, rec.Code, http.StatusTooManyRequests)
+	assert.Equal(t, rec.Header().Get("Retry-After"), "1")
+
+	// Guesses sent at once do not all get to the password check while the first is still being checked
+	server.LoginAttempts = auth.NewMemoryAttemptTracker(policy)
+	codes := make(chan int, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes <- login(fmt.Sprintf("wrong password %d", i)).Code
+		}(i)
+	}
+	wg.Wait()
+	close(codes)
+	checked := 0
+	for code := range codes {
+		if code != http.StatusTooManyRequests {
+			checked++
+		}
+	}
+	assert.Equal(t, checked, 1)
+}
@@ -1,12 +1,19 @@
 package tests
 
 import (
+	"bytes"
 	"fmt"
+	"github.com/gorilla/mux"
 	"github.com/jinzhu/gorm"
 	"github.com/joho/godotenv"
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
 	"github.com/mmosoroohh/Go_Medium_API/api/controllers"
+	"github.com/mmosoroohh/Go_Medium_API/api/mailer"
 	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 )
@@ -22,6 +29,9 @@ func TestMain(m *testing.M) {
 		log.Fatalf("Error while getting env %v\n", err)
 	}
 	Database()
+	server.Mailer = mailer.NewLogMailer(ioutil.Discard)
+	server.LoginAttempts = auth.NewMemoryAttemptTracker(auth.DefaultThrottlePolicy())
+	server.LoginIPAttempts = auth.NewMemoryAttemptTracker(auth.DefaultThrottlePolicy())
 	os.Exit(m.Run())
 }
 
@@ -52,11 +62,11 @@ func Database() {
 }
 
 func refreshUserTable() error {
-	err := server.DB.DropTableIfExists(&models.User{}).Error
+	err := server.DB.DropTableIfExists(&models.User{}, &models.RefreshToken{}, &models.PasswordReset{}, &models.EmailVerification{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.APIKey{}, &models.Identity{}).Error
 	if err != nil {
 		return err
 	}
-	err = server.DB.AutoMigrate(&models.User{}).Error
+	err = server.DB.AutoMigrate(&models.User{}, &models.RefreshToken{}, &models.PasswordReset{}, &models.EmailVerification{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.APIKey{}, &models.Identity{}).Error
 	if err != nil {
 		return err
 	}
@@ -105,11 +115,11 @@ func seedUsers() error {
 
 func refreshUserAndPostTable() error {
 
-	err := server.DB.DropTableIfExists(&models.User{}, &models.Post{}).Error
+	err := server.DB.DropTableIfExists(&models.User{}, &models.Post{}, &models.RefreshToken{}, &models.PasswordReset{}, &models.EmailVerification{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.APIKey{}, &models.Identity{}, &models.PostSlug{}, &models.Tag{}, &models.PostTag{}, &models.PostRevision{}, &models.Clap{}, &models.Comment{}, &models.Follow{}, &models.ReadingList{}, &models.Bookmark{}).Error
 	if err != nil {
 		return err
 	}
-	err = server.DB.AutoMigrate(&models.User{}, &models.Post{}).Error
+	err = server.DB.AutoMigrate(&models.User{}, &models.Post{}, &models.RefreshToken{}, &models.PasswordReset{}, &models.EmailVerification{}, &models.TwoFactor{}, &models.RecoveryCode{}, &models.APIKey{}, &models.Identity{}, &models.PostSlug{}, &models.Tag{}, &models.PostTag{}, &models.PostRevision{}, &models.Clap{}, &models.Comment{}, &models.Follow{}, &models.ReadingList{}, &models.Bookmark{}).Error
 	if err != nil {
 		return err
 	}
@@ -135,6 +145,7 @@ func seedOneUserAndOnePost() (models.Post, error) {
 		Title:    "This is the title john",
 		Content:  "This is the content john",
 		AuthorID: user.ID,
+		Status:   models.PostStatusPublished,
 	}
 	err = server.DB.Model(&models.Post{}).Create(&post).Error
 	if err != nil {
@@ -177,6 +188,7 @@ func seedUsersAndPosts() ([]models.User, []models.Post, error) {
 			log.Fatalf("Can't seed users table: %v", err)
 		}
 		posts[i].AuthorID = users[i].ID
+		posts[i].Status = models.PostStatusPublished
 
 		err = server.DB.Model(&models.Post{}).Create(&posts[i]).Error
 		if err != nil {
@@ -185,3 +197,56 @@ func seedUsersAndPosts() ([]models.User, []models.Post, error) {
 	}
 	return users, posts, nil
 }
+
+func seedUserWithRole(username, role string) models.User {
+	user := models.User{
+		Username: username,
+		Email:    fmt.Sprintf("%s@gmail.com", username),
+		Password: "password",
+		Role:     role,
+	}
+	err := server.DB.Model(&models.User{}).Create(&user).Error
+	if err != nil {
+		log.Fatalf("Can't seed user table: %v", err)
+	}
+	return user
+}
+
+// signIn logs in users seeded by seedUserWithRole, returning their access tokens by user id
+func signIn(users ...models.User) map[uint32]string {
+	tokens := map[uint32]string{}
+	for _, person := range users {
+		details, err := server.SignIn(person.Email, "password")
+		if err != nil {
+			log.Fatalf("Error occurred login: %v\n", err)
+		}
+		tokens[person.ID] = details.AccessToken
+	}
+	return tokens
+}
+
+func seedPost(author models.User, title, status string) models.Post {
+	post := models.Post{Title: title, Content: "Content", AuthorID: author.ID, Status: status}
+	post.Prepare()
+	_, err := post.SavePost(server.DB)
+	if err != nil {
+		log.Fatalf("Cannot save post: %v", err)
+	}
+	return post
+}
+
+func postRequest(handler http.HandlerFunc, method, url string, vars map[string]string, body, accessToken string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+	if err != nil {
+		log.Fatalf("Error Occurred: %v\n", err)
+	}
+	if vars != nil {
+		req = mux.SetURLVars(req, vars)
+	}
+	if accessToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", accessToken))
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
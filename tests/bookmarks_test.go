@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+type bookmarkPage struct {
+	Data       []models.Bookmark `json:"data"`
+	NextCursor string            `json:"next_cursor"`
+}
+
+func TestBookmarksAndLists(t *testing.T) {
+
+	err := refreshUserAndPostTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	author := seedUserWithRole("author", auth.RoleAuthor)
+	reader := seedUserWithRole("reader", auth.RoleReader)
+	other := seedUserWithRole("other", auth.RoleReader)
+	tokens := signIn(author, reader, other)
+	posts := []models.Post{}
+	for i := 0; i < 3; i++ {
+		posts = append(posts, seedPost(author, fmt.Sprintf("Save me %d", i), models.PostStatusPublished))
+	}
+	token := tokens[reader.ID]
+	for _, post := range posts {
+		vars := map[string]string{"id": strconv.Itoa(int(post.ID))}
+		assert.Equal(t, postRequest(server.BookmarkPost, "POST", "/posts/bookmark", vars, "", token).Code, http.StatusCreated)
+	}
+	firstVars := map[string]string{"id": strconv.Itoa(int(posts[0].ID))}
+	assert.Equal(t, postRequest(server.BookmarkPost, "POST", "/posts/bookmark", firstVars, "", token).Code, http.StatusConflict)
+	assert.Equal(t, postRequest(server.BookmarkPost, "POST", "/posts/bookmark", firstVars, "", "").Code, http.StatusUnauthorized)
+
+	// Posts tell the user asking whether they bookmarked them
+	rec := postRequest(server.GetPost, "GET", "/posts", firstVars, "", token)
+	found := models.Post{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &found)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, found.Bookmarked, true)
+	rec = postRequest(server.GetPost, "GET", "/posts", firstVars, "", tokens[other.ID])
+	found = models.Post{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &found)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, found.Bookmarked, false)
+
+	bookmarks := func(query string) bookmarkPage {
+		rec := postRequest(server.GetBookmarks, "GET", "/me/bookmarks"+query, nil, "", token)
+		assert.Equal(t, rec.Code, http.StatusOK)
+		page := bookmarkPage{}
+		err := json.Unmarshal([]byte(rec.Body.String()), &page)
+		if err != nil {
+			t.Errorf("Cannot convert to json: %v", err)
+		}
+		return page
+	}
+	page := bookmarks("?limit=2")
+	assert.Equal(t, len(page.Data), 2)
+	assert.Equal(t, page.Data[0].Post.Title, "Save me 2")
+	assert.Equal(t, page.Data[0].Post.Bookmarked, true)
+	page = bookmarks("?limit=2&cursor=" + page.NextCursor)
+	assert.Equal(t, len(page.Data), 1)
+	assert.Equal(t, page.NextCursor, "")
+
+	// Posts in the trash leave the lists, purged ones for good
+	_, err = posts[1].DeletePost(server.DB, posts[1].ID, author.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(bookmarks("").Data), 2)
+	assert.Equal(t, models.PurgePost(server.DB, posts[1].ID), nil)
+	count := 0
+	server.DB.Model(&models.Bookmark{}).Where("post_id = ?", posts[1].ID).Count(&count)
+	assert.Equal(t, count, 0)
+	assert.Equal(t, postRequest(server.UnbookmarkPost, "DELETE", "/posts/bookmark", firstVars, "", token).Code, http.StatusNoContent)
+	assert.Equal(t, postRequest(server.UnbookmarkPost, "DELETE", "/posts/bookmark", firstVars, "", token).Code, http.StatusNotFound)
+	assert.Equal(t, len(bookmarks("").Data), 1)
+
+	// Custom lists are private until they are made public, and only their owner changes them
+	rec = postRequest(server.CreateList, "POST", "/me/lists", nil, `{"name": "Weekend"}`, token)
+	assert.Equal(t, rec.Code, http.StatusCreated)
+	list := models.ReadingList{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &list)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, postRequest(server.CreateList, "POST", "/me/lists", nil, `{"name": "Weekend"}`, token).Code, http.StatusConflict)
+	assert.Equal(t, postRequest(server.CreateList, "POST", "/me/lists", nil, `{"name": ""}`, token).Code, http.StatusUnprocessableEntity)
+
+	// A list that took the default list's name before it was kept for it becomes the default one, and private
+	legacy := models.ReadingList{UserID: other.ID, Name: models.DefaultListName, Public: true}
+	err = server.DB.Create(&legacy).Error
+	if err != nil {
+		log.Fatalf("Cannot save list: %v", err)
+	}
+	assert.Equal(t, postRequest(server.BookmarkPost, "POST", "/posts/bookmark", firstVars, "", tokens[other.ID]).Code, http.StatusCreated)
+	legacyVars := map[string]string{"id": strconv.Itoa(int(legacy.ID))}
+	assert.Equal(t, postRequest(server.GetListPosts, "GET", "/lists/posts", legacyVars, "", "").Code, http.StatusNotFound)
+	assert.Equal(t, postRequest(server.CreateList, "POST", "/me/lists", nil, `{"name": "reading List"}`, tokens[other.ID]).Code, http.StatusConflict)
+	listVars := map[string]string{"id": strconv.Itoa(int(list.ID))}
+	assert.Equal(t, postRequest(server.AddListPost, "POST", "/lists/posts", listVars, fmt.Sprintf(`{"post_id": %d}`, posts[2].ID), token).Code, http.StatusCreated)
+	assert.Equal(t, postRequest(server.AddListPost, "POST", "/lists/posts", listVars, fmt.Sprintf(`{"post_id": %d}`, posts[0].ID), tokens[other.ID]).Code, http.StatusNotFound)
+	assert.Equal(t, postRequest(server.GetListPosts, "GET", "/lists/posts", listVars, "", tokens[other.ID]).Code, http.StatusNotFound)
+	assert.Equal(t, postRequest(server.UpdateList, "PUT", "/lists", listVars, `{"public": true}`, token).Code, http.StatusOK)
+	assert.Equal(t, postRequest(server.UpdateList, "PUT", "/lists", listVars, `{"public": false}`, tokens[other.ID]).Code, http.StatusUnauthorized)
+	rec = postRequest(server.GetListPosts, "GET", "/lists/posts", listVars, "", "")
+	assert.Equal(t, rec.Code, http.StatusOK)
+	page = bookmarkPage{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &page)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, len(page.Data), 1)
+	assert.Equal(t, page.Data[0].PostID, posts[2].ID)
+
+	userVars := map[string]string{"id": strconv.Itoa(int(reader.ID))}
+	rec = postRequest(server.GetUserLists, "GET", "/users/lists", userVars, "", "")
+	lists := []models.ReadingList{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &lists)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, len(lists), 1)
+	assert.Equal(t, lists[0].Name, "Weekend")
+	assert.Equal(t, lists[0].PostCount, 1)
+	rec = postRequest(server.GetMyLists, "GET", "/me/lists", nil, "", token)
+	lists = []models.ReadingList{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &lists)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, len(lists), 2)
+	assert.Equal(t, lists[0].Default, true)
+
+	defaultVars := map[string]string{"id": strconv.Itoa(int(lists[0].ID))}
+	assert.Equal(t, postRequest(server.DeleteList, "DELETE", "/lists", defaultVars, "", token).Code, http.StatusUnprocessableEntity)
+	assert.Equal(t, postRequest(server.DeleteList, "DELETE", "/lists", listVars, "", token).Code, http.StatusNoContent)
+	assert.Equal(t, postRequest(server.GetList, "GET", "/lists", listVars, "", token).Code, http.StatusNotFound)
+}
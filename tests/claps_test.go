@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/controllers"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func TestClaps(t *testing.T) {
+
+	err := refreshUserAndPostTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	author := seedUserWithRole("author", auth.RoleAuthor)
+	reader := seedUserWithRole("reader", auth.RoleReader)
+	other := seedUserWithRole("other", auth.RoleReader)
+	tokens := signIn(author, reader, other)
+	post := seedPost(author, "Clap for me", models.PostStatusPublished)
+	vars := map[string]string{"id": strconv.Itoa(int(post.ID))}
+
+	clap := func(token, body string) controllers.ClapResult {
+		rec := postRequest(server.ClapPost, "POST", "/posts/claps", vars, body, token)
+		assert.Equal(t, rec.Code, http.StatusOK)
+		result := controllers.ClapResult{}
+		err := json.Unmarshal([]byte(rec.Body.String()), &result)
+		if err != nil {
+			t.Errorf("Cannot convert to json: %v", err)
+		}
+		return result
+	}
+	result := clap(tokens[reader.ID], "")
+	assert.Equal(t, result.Claps, 1)
+	assert.Equal(t, result.ClapCount, int64(1))
+	result = clap(tokens[reader.ID], `{"claps": 10}`)
+	assert.Equal(t, result.Claps, 11)
+	result = clap(tokens[other.ID], `{"claps": 5}`)
+	assert.Equal(t, result.Claps, 5)
+	assert.Equal(t, result.ClapCount, int64(16))
+
+	// Claps past the limit are dropped, once it is reached there are no more
+	result = clap(tokens[reader.ID], `{"claps": 45}`)
+	assert.Equal(t, result.Claps, models.MaxClapsPerUser)
+	assert.Equal(t, result.ClapCount, int64(55))
+	assert.Equal(t, postRequest(server.ClapPost, "POST", "/posts/claps", vars, "", tokens[reader.ID]).Code, http.StatusUnprocessableEntity)
+	assert.Equal(t, postRequest(server.ClapPost, "POST", "/posts/claps", vars, `{"claps": 0}`, tokens[other.ID]).Code, http.StatusUnprocessableEntity)
+	assert.Equal(t, postRequest(server.ClapPost, "POST", "/posts/claps", vars, "", "").Code, http.StatusUnauthorized)
+
+	// Posts say whether the user asking clapped
+	rec := postRequest(server.GetPost, "GET", "/posts", vars, "", tokens[other.ID])
+	found := models.Post{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &found)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, found.ClapCount, int64(55))
+	assert.Equal(t, found.Clapped, true)
+	rec = postRequest(server.GetPosts, "GET", "/posts", nil, "", tokens[author.ID])
+	page := postPage{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &page)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, len(page.Data), 1)
+	assert.Equal(t, page.Data[0].ClapCount, int64(55))
+	assert.Equal(t, page.Data[0].Clapped, false)
+
+	rec = postRequest(server.GetClaps, "GET", "/posts/claps", vars, "", "")
+	assert.Equal(t, rec.Code, http.StatusOK)
+	clappers := []models.Clapper{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &clappers)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, len(clappers), 2)
+	assert.Equal(t, clappers[0].Username, reader.Username)
+	assert.Equal(t, clappers[0].Count, models.MaxClapsPerUser)
+
+	// Taking claps back takes them off the count
+	rec = postRequest(server.UnclapPost, "DELETE", "/posts/claps", vars, "", tokens[reader.ID])
+	assert.Equal(t, rec.Code, http.StatusOK)
+	result = controllers.ClapResult{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &result)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, result.ClapCount, int64(5))
+
+	// Concurrent claps of one user neither get lost nor get past the limit
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := models.Clap{}
+			c.AddClaps(server.DB, post.ID, reader.ID, 7)
+		}()
+	}
+	wg.Wait()
+	c := models.Clap{}
+	err = server.DB.Model(&models.Clap{}).Where("post_id = ? and user_id = ?", post.ID, reader.ID).Take(&c).Error
+	assert.Equal(t, err, nil)
+	stored := models.Post{}
+	err = server.DB.Model(&models.Post{}).Where("id = ?", post.ID).Take(&stored).Error
+	assert.Equal(t, err, nil)
+	assert.Equal(t, c.Count <= models.MaxClapsPerUser, true)
+	assert.Equal(t, stored.ClapCount, int64(5+c.Count))
+}
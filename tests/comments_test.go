@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func TestComments(t *testing.T) {
+
+	err := refreshUserAndPostTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	author := seedUserWithRole("author", auth.RoleAuthor)
+	reader := seedUserWithRole("reader", auth.RoleReader)
+	other := seedUserWithRole("other", auth.RoleReader)
+	tokens := signIn(author, reader, other)
+	post := seedPost(author, "Talk to me", models.PostStatusPublished)
+	vars := map[string]string{"id": strconv.Itoa(int(post.ID))}
+
+	comment := func(token, body string) models.Comment {
+		rec := postRequest(server.CreateComment, "POST", "/posts/comments", vars, body, token)
+		assert.Equal(t, rec.Code, http.StatusCreated)
+		created := models.Comment{}
+		err := json.Unmarshal([]byte(rec.Body.String()), &created)
+		if err != nil {
+			t.Errorf("Cannot convert to json: %v", err)
+		}
+		return created
+	}
+	first := comment(tokens[reader.ID], `{"content": "Nice *post*"}`)
+	assert.Equal(t, first.AuthorID, reader.ID)
+	assert.Equal(t, first.Depth, 0)
+	assert.Equal(t, first.ContentHTML, "<p>Nice <em>post</em></p>\n")
+	reply := comment(tokens[author.ID], fmt.Sprintf(`{"content": "Thanks", "parent_id": %d}`, first.ID))
+	assert.Equal(t, reply.Depth, 1)
+	comment(tokens[other.ID], fmt.Sprintf(`{"content": "Agreed", "parent_id": %d}`, reply.ID))
+	spam := comment(tokens[other.ID], `{"content": "Buy things"}`)
+	assert.Equal(t, postRequest(server.CreateComment, "POST", "/posts/comments", vars, `{"content": ""}`, tokens[other.ID]).Code, http.StatusUnprocessableEntity)
+	assert.Equal(t, postRequest(server.CreateComment, "POST", "/posts/comments", vars, `{"content": "Hi", "parent_id": 999}`, tokens[other.ID]).Code, http.StatusUnprocessableEntity)
+	assert.Equal(t, postRequest(server.CreateComment, "POST", "/posts/comments", vars, `{"content": "Hi"}`, "").Code, http.StatusUnauthorized)
+
+	// Only the commenter or an editor may change or delete a comment, the post's author may hide it
+	commentVars := map[string]string{"id": vars["id"], "cid": strconv.Itoa(int(spam.ID))}
+	assert.Equal(t, postRequest(server.UpdateComment, "PUT", "/posts/comments", commentVars, `{"content": "Changed"}`, tokens[reader.ID]).Code, http.StatusUnauthorized)
+	assert.Equal(t, postRequest(server.DeleteComment, "DELETE", "/posts/comments", commentVars, "", tokens[author.ID]).Code, http.StatusUnauthorized)
+	assert.Equal(t, postRequest(server.HideComment, "POST", "/posts/comments/hide", commentVars, "", tokens[other.ID]).Code, http.StatusUnauthorized)
+	assert.Equal(t, postRequest(server.HideComment, "POST", "/posts/comments/hide", commentVars, "", tokens[author.ID]).Code, http.StatusOK)
+	rec := postRequest(server.UpdateComment, "PUT", "/posts/comments", commentVars, `{"content": "Buy more things"}`, tokens[other.ID])
+	assert.Equal(t, rec.Code, http.StatusOK)
+
+	// Hidden comments keep their place in the thread but not their content, and do not count
+	rec = postRequest(server.GetComments, "GET", "/posts/comments", vars, "", tokens[reader.ID])
+	assert.Equal(t, rec.Code, http.StatusOK)
+	threads := []models.Comment{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &threads)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, len(threads), 2)
+	assert.Equal(t, len(threads[0].Replies), 1)
+	assert.Equal(t, len(threads[0].Replies[0].Replies), 1)
+	assert.Equal(t, threads[0].Replies[0].Replies[0].Content, "Agreed")
+	assert.Equal(t, threads[1].Hidden, true)
+	assert.Equal(t, threads[1].Content, "")
+	rec = postRequest(server.GetComments, "GET", "/posts/comments?view=flat", vars, "", tokens[other.ID])
+	flat := []models.Comment{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &flat)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, len(flat), 4)
+	assert.Equal(t, flat[2].Depth, 2)
+	assert.Equal(t, flat[3].Content, "Buy more things")
+
+	// Anyone may read the comments, so their authors come without the rest of their account
+	raw := []map[string]interface{}{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &raw)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	commenter := raw[0]["author"].(map[string]interface{})
+	assert.Equal(t, commenter["username"], "reader")
+	_, ok := commenter["password"]
+	assert.Equal(t, ok, false)
+	_, ok = commenter["email"]
+	assert.Equal(t, ok, false)
+
+	rec = postRequest(server.GetPosts, "GET", "/posts", nil, "", "")
+	page := postPage{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &page)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, len(page.Data), 1)
+	assert.Equal(t, page.Data[0].CommentCount, 3)
+
+	// A deleted comment with replies stays as an empty placeholder
+	commentVars["cid"] = strconv.Itoa(int(first.ID))
+	assert.Equal(t, postRequest(server.DeleteComment, "DELETE", "/posts/comments", commentVars, "", tokens[reader.ID]).Code, http.StatusNoContent)
+	rec = postRequest(server.GetComments, "GET", "/posts/comments", vars, "", "")
+	threads = []models.Comment{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &threads)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, threads[0].Deleted, true)
+	assert.Equal(t, threads[0].Content, "")
+	assert.Equal(t, len(threads[0].Replies), 1)
+	counts, err := models.CommentCounts(server.DB, []uint64{post.ID})
+	assert.Equal(t, err, nil)
+	assert.Equal(t, counts[post.ID], 2)
+}
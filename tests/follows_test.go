@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func TestFollowsAndFeed(t *testing.T) {
+
+	err := refreshUserAndPostTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	followed := seedUserWithRole("followed", auth.RoleAuthor)
+	stranger := seedUserWithRole("stranger", auth.RoleAuthor)
+	reader := seedUserWithRole("reader", auth.RoleReader)
+	token := signIn(reader)[reader.ID]
+	for i := 0; i < 5; i++ {
+		for _, author := range []models.User{followed, stranger} {
+			seedPost(author, fmt.Sprintf("%s %d", author.Username, i), models.PostStatusPublished)
+		}
+	}
+	seedPost(followed, "Not yet", models.PostStatusDraft)
+
+	followVars := map[string]string{"id": strconv.Itoa(int(followed.ID))}
+	assert.Equal(t, postRequest(server.FollowUser, "POST", "/users/follow", followVars, "", token).Code, http.StatusCreated)
+	assert.Equal(t, postRequest(server.FollowUser, "POST", "/users/follow", followVars, "", token).Code, http.StatusConflict)
+	selfVars := map[string]string{"id": strconv.Itoa(int(reader.ID))}
+	assert.Equal(t, postRequest(server.FollowUser, "POST", "/users/follow", selfVars, "", token).Code, http.StatusUnprocessableEntity)
+	assert.Equal(t, postRequest(server.FollowUser, "POST", "/users/follow", map[string]string{"id": "999"}, "", token).Code, http.StatusNotFound)
+
+	rec := postRequest(server.GetFollowers, "GET", "/users/followers", followVars, "", "")
+	assert.Equal(t, rec.Code, http.StatusOK)
+	followers := models.FollowList{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &followers)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, followers.Count, 1)
+	assert.Equal(t, followers.Users[0].Username, reader.Username)
+	rec = postRequest(server.GetFollowing, "GET", "/users/following", selfVars, "", "")
+	following := models.FollowList{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &following)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, following.Count, 1)
+	assert.Equal(t, following.Users[0].UserID, followed.ID)
+
+	// The feed pages through the followed author's published posts, latest first
+	feed := func(query string) ([]models.Post, string) {
+		rec := postRequest(server.GetFeed, "GET", "/feed"+query, nil, "", token)
+		assert.Equal(t, rec.Code, http.StatusOK)
+		page := postPage{}
+		err := json.Unmarshal([]byte(rec.Body.String()), &page)
+		if err != nil {
+			t.Errorf("Cannot convert to json: %v", err)
+		}
+		return page.Data, page.NextCursor
+	}
+	posts, next := feed("?limit=2")
+	titles := []string{}
+	for next != "" || len(posts) > 0 {
+		for _, post := range posts {
+			assert.Equal(t, post.AuthorID, followed.ID)
+			titles = append(titles, post.Title)
+		}
+		if next == "" {
+			break
+		}
+		posts, next = feed("?limit=2&cursor=" + next)
+	}
+	assert.Equal(t, titles, []string{"followed 4", "followed 3", "followed 2", "followed 1", "followed 0"})
+	assert.Equal(t, postRequest(server.GetFeed, "GET", "/feed?cursor=nonsense", nil, "", token).Code, http.StatusBadRequest)
+	assert.Equal(t, postRequest(server.GetFeed, "GET", "/feed", nil, "", "").Code, http.StatusUnauthorized)
+
+	assert.Equal(t, postRequest(server.UnfollowUser, "DELETE", "/users/follow", followVars, "", token).Code, http.StatusNoContent)
+	assert.Equal(t, postRequest(server.UnfollowUser, "DELETE", "/users/follow", followVars, "", token).Code, http.StatusNotFound)
+	posts, next = feed("")
+	assert.Equal(t, len(posts), 0)
+	assert.Equal(t, next, "")
+}
@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func requestWithToken(token string) *http.Request {
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", token))
+	return req
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	defer auth.SetKeySet(nil)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey, err := auth.NewKey(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := auth.NewKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sign with RS256
+	ks, err := auth.NewKeySet(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.SetKeySet(ks)
+	oldToken, err := auth.CreateToken(1, auth.RoleAuthor)
+	assert.Equal(t, err, nil)
+	uid, err := auth.ExtractTokenID(requestWithToken(oldToken))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, uid, uint32(1))
+
+	// Rotate to EdDSA while still accepting the RS256 key
+	ks, err = auth.NewKeySet(newKey, &auth.Key{ID: oldKey.ID, Method: oldKey.Method, Public: oldKey.Public})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.SetKeySet(ks)
+	newToken, err := auth.CreateToken(2, auth.RoleAuthor)
+	assert.Equal(t, err, nil)
+	uid, err = auth.ExtractTokenID(requestWithToken(newToken))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, uid, uint32(2))
+	uid, err = auth.ExtractTokenID(requestWithToken(oldToken))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, uid, uint32(1))
+
+	jwks := auth.PublicJWKS()
+	assert.Equal(t, len(jwks.Keys), 2)
+
+	// Once the old key is retired its tokens are rejected
+	ks, err = auth.NewKeySet(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.SetKeySet(ks)
+	err = auth.ValidToken(requestWithToken(oldToken))
+	assert.NotEqual(t, err, nil)
+
+	// And HMAC tokens are not accepted by an asymmetric key set
+	auth.SetKeySet(nil)
+	hmacToken, err := auth.CreateToken(3, auth.RoleAuthor)
+	assert.Equal(t, err, nil)
+	auth.SetKeySet(ks)
+	err = auth.ValidToken(requestWithToken(hmacToken))
+	assert.NotEqual(t, err, nil)
+}
@@ -51,7 +51,7 @@ func TestSignIn(t *testing.T) {
 		if err != nil {
 			assert.Equal(t, err, errors.New(v.errorMessage))
 		} else {
-			assert.NotEqual(t, token, "")
+			assert.NotEqual(t, token.AccessToken, "")
 		}
 	}
 }
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/middlewares"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func TestLogout(t *testing.T) {
+
+	auth.SetRevocationStore(auth.NewMemoryRevocationStore())
+
+	err := refreshUserTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	person, err := seedUser()
+	if err != nil {
+		log.Fatalf("Error Occurred seeding user %v\n", err)
+	}
+	tokens, err := server.SignIn(person.Email, "Password")
+	if err != nil {
+		log.Fatalf("Error occurred login: %v\n", err)
+	}
+	tokenString := fmt.Sprintf("Bearer %v", tokens.AccessToken)
+	handler := middlewares.SetMiddlewareAuthentication(server.Logout)
+
+	req, err := http.NewRequest("POST", "/logout", bytes.NewBufferString(""))
+	if err != nil {
+		t.Errorf("Error Occurred: %v\n", err)
+	}
+	req.Header.Set("Authorization", tokenString)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, rec.Code, http.StatusNoContent)
+
+	// The same access token is rejected once revoked
+	req, err = http.NewRequest("POST", "/logout", bytes.NewBufferString(""))
+	if err != nil {
+		t.Errorf("Error Occurred: %v\n", err)
+	}
+	req.Header.Set("Authorization", tokenString)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, rec.Code, http.StatusUnauthorized)
+}
+
+func TestMemoryRevocationStorePurge(t *testing.T) {
+	store := auth.NewMemoryRevocationStore()
+	now := time.Now()
+
+	_ = store.Revoke("expired", now.Add(-time.Minute))
+	_ = store.Revoke("active", now.Add(time.Minute))
+
+	purged, err := store.Purge(now)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, purged, int64(1))
+
+	revoked, _ := store.IsRevoked("expired")
+	assert.Equal(t, revoked, false)
+	revoked, _ = store.IsRevoked("active")
+	assert.Equal(t, revoked, true)
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/markdown"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	samples := []struct {
+		markdown string
+		html     string
+	}{
+		{markdown: "# Title\n\nSome **bold** text", html: "<h1>Title</h1>\n<p>Some <strong>bold</strong> text</p>\n"},
+		{markdown: "a & b < c", html: "<p>a &amp; b &lt; c</p>\n"},
+		{markdown: "Hi <script>alert(1)</script>", html: "<p>Hi </p>\n"},
+		{markdown: "[click](javascript:alert(1))", html: "<p>click</p>\n"},
+		{markdown: `<img src="cat.png" onerror="alert(1)">`, html: `<img src="cat.png">`},
+		{markdown: `<iframe src="https://evil.example/embed"></iframe>`, html: ""},
+		{
+			markdown: `<iframe src="https://www.youtube.com/embed/abc" width="560" onload="alert(1)"></iframe>`,
+			html:     `<iframe src="https://www.youtube.com/embed/abc" width="560"></iframe>`,
+		},
+	}
+	for _, v := range samples {
+		assert.Equal(t, markdown.Render(v.markdown), v.html)
+	}
+
+	markdown.SetEmbedHosts(nil)
+	defer markdown.SetEmbedHosts(markdown.DefaultEmbedHosts)
+	assert.Equal(t, markdown.Render(`<iframe src="https://www.youtube.com/embed/abc"></iframe>`), "")
+}
+
+func TestPostMarkdownContent(t *testing.T) {
+
+	err := refreshUserAndPostTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	author := seedUserWithRole("author", auth.RoleAuthor)
+	token := signIn(author)[author.ID]
+
+	// Articles are no longer cut off at 255 characters
+	long := strings.Repeat("word ", 200)
+	input, _ := json.Marshal(map[string]interface{}{"title": "Long read", "content_markdown": "## Intro\n\n" + long, "author_id": author.ID})
+	rec := postRequest(server.CreatePost, "POST", "/posts", nil, string(input), token)
+	assert.Equal(t, rec.Code, http.StatusCreated)
+	created := models.Post{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &created)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, created.Content, "## Intro\n\n"+strings.TrimSpace(long))
+	assert.Equal(t, strings.HasPrefix(created.ContentHTML, "<h2>Intro</h2>"), true)
+
+	// The html is always rendered from the markdown, whatever the client sends, and "content" still works
+	vars := map[string]string{"id": strconv.Itoa(int(created.ID))}
+	inputJSON := fmt.Sprintf(`{"title": "Long read", "content": "Fish & *chips*", "content_html": "<script>alert(1)</script>", "author_id": %d}`, author.ID)
+	rec = postRequest(server.UpdatePost, "PUT", "/posts", vars, inputJSON, token)
+	assert.Equal(t, rec.Code, http.StatusOK)
+	responseMap := make(map[string]interface{})
+	err = json.Unmarshal([]byte(rec.Body.String()), &responseMap)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, responseMap["content_markdown"], "Fish & *chips*")
+	assert.Equal(t, responseMap["content_html"], "<p>Fish &amp; <em>chips</em></p>\n")
+
+	post := models.Post{}
+	_, err = post.SinglePost(server.DB, created.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, post.ContentHTML, "<p>Fish &amp; <em>chips</em></p>\n")
+
+	// Posts saved before the switch were html escaped and have no rendered content yet
+	err = server.DB.DropTable(&models.Post{}).CreateTable(&legacyPost{}).Error
+	if err != nil {
+		log.Fatal(err)
+	}
+	old := legacyPost{Title: "Before markdown", Content: "Fish &amp; *chips*", AuthorID: author.ID}
+	err = server.DB.Create(&old).Error
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = models.MigrateContent(server.DB)
+	assert.Equal(t, err, nil)
+	err = server.DB.AutoMigrate(&models.Post{}).Error
+	if err != nil {
+		log.Fatal(err)
+	}
+	post = models.Post{}
+	err = server.DB.Model(&models.Post{}).Where("id = ?", old.ID).Take(&post).Error
+	assert.Equal(t, err, nil)
+	assert.Equal(t, post.Content, "Fish & *chips*")
+	assert.Equal(t, post.ContentHTML, "<p>Fish &amp; <em>chips</em></p>\n")
+
+	// It only runs once, posts without rendered content later on are not unescaped again
+	err = server.DB.Model(&models.Post{}).Where("id = ?", old.ID).UpdateColumns(map[string]interface{}{"content": "&amp;", "content_html": ""}).Error
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = models.MigrateContent(server.DB)
+	assert.Equal(t, err, nil)
+	post = models.Post{}
+	err = server.DB.Model(&models.Post{}).Where("id = ?", old.ID).Take(&post).Error
+	assert.Equal(t, err, nil)
+	assert.Equal(t, post.Content, "&amp;")
+}
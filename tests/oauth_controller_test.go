@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/oauth"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+// oauthSignIn runs the whole authorization code flow against the fake issuer, tamper may change the callback url
+func oauthSignIn(t *testing.T, tamper func(callback *url.URL)) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", "/auth/fake/login", nil)
+	if err != nil {
+		t.Errorf("Error Occurred: %v\n", err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"provider": "fake"})
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(server.OAuthLogin).ServeHTTP(rec, req)
+	assert.Equal(t, rec.Code, http.StatusFound)
+	cookies := rec.Result().Cookies()
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Error Occurred: %v\n", err)
+	}
+	res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusFound)
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("Error Occurred: %v\n", err)
+	}
+	if tamper != nil {
+		tamper(callback)
+	}
+
+	req, err = http.NewRequest("GET", callback.String(), nil)
+	if err != nil {
+		t.Errorf("Error Occurred: %v\n", err)
+	}
+	req = mux.SetURLVars(req, map[string]string{"provider": "fake"})
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(server.OAuthCallback).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOAuthLogin(t *testing.T) {
+
+	fake := newFakeOIDCServer()
+	defer fake.Close()
+	fake.Subject, fake.Email, fake.EmailVerified, fake.Name = "fake-1", "jane@example.com", true, "Jane Doe"
+
+	provider, err := oauth.NewProvider(context.Background(), "fake", fake.URL, "client", "secret", "http://localhost/auth/fake/callback")
+	if err != nil {
+		log.Fatalf("Cannot discover the fake issuer: %v\n", err)
+	}
+	server.OAuthProviders = map[string]*oauth.Provider{"fake": provider}
+	defer func() { server.OAuthProviders = nil }()
+
+	err = refreshUserTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	existing, err := seedUser()
+	if err != nil {
+		log.Fatalf("Error Occurred seeding user %v\n", err)
+	}
+
+	// An unknown identity signs up a new, verified user
+	rec := oauthSignIn(t, nil)
+	assert.Equal(t, rec.Code, http.StatusOK)
+	responseMap := make(map[string]interface{})
+	err = json.Unmarshal([]byte(rec.Body.String()), &responseMap)
+	if err != nil {
+		t.Errorf("Error occurred converting to json: %v", err)
+	}
+	assert.NotEqual(t, responseMap["access_token"], nil)
+
+	identity := models.Identity{}
+	_, err = identity.FindIdentity(server.DB, "fake", "fake-1")
+	assert.Equal(t, err, nil)
+	created := models.User{}
+	_, err = created.SingleUser(server.DB, identity.UserID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, created.Email, "jane@example.com")
+	assert.Equal(t, created.Username, "jane_doe")
+	assert.Equal(t, created.EmailVerified(), true)
+
+	// Signing in again uses the linked user
+	rec = oauthSignIn(t, nil)
+	assert.Equal(t, rec.Code, http.StatusOK)
+	count := 0
+	server.DB.Model(&models.User{}).Count(&count)
+	assert.Equal(t, count, 2)
+
+	// An unverified email does not get to take over an existing account
+	fake.Subject, fake.Email, fake.EmailVerified = "fake-2", existing.Email, false
+	rec = oauthSignIn(t, nil)
+	assert.Equal(t, rec.Code, http.StatusConflict)
+
+	// Nor does a verified one while the account never verified it, whoever signed up may not own the email
+	fake.EmailVerified = true
+	rec = oauthSignIn(t, nil)
+	assert.Equal(t, rec.Code, http.StatusConflict)
+	_, err = identity.FindIdentity(server.DB, "fake", "fake-2")
+	assert.NotEqual(t, err, nil)
+
+	// Once both verified it the identity is linked to the account
+	err = server.DB.Model(&models.User{}).Where("id = ?", existing.ID).UpdateColumn("email_verified_at", time.Now()).Error
+	if err != nil {
+		log.Fatalf("Cannot verify the email: %v\n", err)
+	}
+	rec = oauthSignIn(t, nil)
+	assert.Equal(t, rec.Code, http.StatusOK)
+	identity = models.Identity{}
+	_, err = identity.FindIdentity(server.DB, "fake", "fake-2")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, identity.UserID, existing.ID)
+
+	// The state has to match the one on the browser that started the flow
+	rec = oauthSignIn(t, func(callback *url.URL) {
+		values := callback.Query()
+		values.Set("state", "forged")
+		callback.RawQuery = values.Encode()
+	})
+	assert.Equal(t, rec.Code, http.StatusUnauthorized)
+
+	// Codes the issuer never handed out are rejected
+	rec = oauthSignIn(t, func(callback *url.URL) {
+		values := callback.Query()
+		values.Set("code", "unknown")
+		callback.RawQuery = values.Encode()
+	})
+	assert.Equal(t, rec.Code, http.StatusUnauthorized)
+}
@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func TestPostsPagination(t *testing.T) {
+
+	err := refreshUserAndPostTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	author := seedUserWithRole("author", auth.RoleAuthor)
+	other := seedUserWithRole("other", auth.RoleAuthor)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		by := author
+		if i%2 == 1 {
+			by = other
+		}
+		post := seedPost(by, fmt.Sprintf("Post %d", i), models.PostStatusPublished)
+		err = server.DB.Model(&models.Post{}).Where("id = ?", post.ID).UpdateColumn("created_at", start.AddDate(0, 0, i)).Error
+		if err != nil {
+			log.Fatalf("Cannot date post: %v", err)
+		}
+	}
+
+	list := func(url string) postPage {
+		rec := postRequest(server.GetPosts, "GET", url, nil, "", "")
+		assert.Equal(t, rec.Code, http.StatusOK)
+		page := postPage{}
+		err := json.Unmarshal([]byte(rec.Body.String()), &page)
+		if err != nil {
+			t.Errorf("Cannot convert to json: %v", err)
+		}
+		return page
+	}
+	titles := func(page postPage) []string {
+		titles := []string{}
+		for _, post := range page.Data {
+			titles = append(titles, post.Title)
+		}
+		return titles
+	}
+
+	// Latest first by default, the links walk forward and back again
+	page := list("/posts?limit=3")
+	assert.Equal(t, page.Total, 7)
+	assert.Equal(t, titles(page), []string{"Post 6", "Post 5", "Post 4"})
+	assert.Equal(t, page.PrevCursor, "")
+	page = list(page.Links.Next)
+	assert.Equal(t, titles(page), []string{"Post 3", "Post 2", "Post 1"})
+	third := list(page.Links.Next)
+	assert.Equal(t, titles(third), []string{"Post 0"})
+	assert.Equal(t, third.NextCursor, "")
+	page = list(third.Links.Prev)
+	assert.Equal(t, titles(page), []string{"Post 3", "Post 2", "Post 1"})
+	page = list(page.Links.Prev)
+	assert.Equal(t, titles(page), []string{"Post 6", "Post 5", "Post 4"})
+
+	// Other sorts keep their order across pages
+	page = list("/posts?limit=4&sort=title")
+	assert.Equal(t, titles(page), []string{"Post 0", "Post 1", "Post 2", "Post 3"})
+	page = list(page.Links.Next)
+	assert.Equal(t, titles(page), []string{"Post 4", "Post 5", "Post 6"})
+
+	// Filters narrow the listing and its total down
+	page = list("/posts?author_id=" + strconv.Itoa(int(other.ID)))
+	assert.Equal(t, page.Total, 3)
+	assert.Equal(t, titles(page), []string{"Post 5", "Post 3", "Post 1"})
+	page = list("/posts?created_after=2026-01-03&created_before=2026-01-05")
+	assert.Equal(t, titles(page), []string{"Post 3", "Post 2"})
+
+	for _, url := range []string{"/posts?limit=0", "/posts?limit=101", "/posts?sort=password", "/posts?cursor=nonsense", "/posts?created_after=yesterday", "/posts?author_id=me"} {
+		assert.Equal(t, postRequest(server.GetPosts, "GET", url, nil, "", "").Code, http.StatusBadRequest)
+	}
+}
+
+func TestUsersPagination(t *testing.T) {
+
+	err := refreshUserAndPostTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, name := range []string{"carol", "alice", "dave", "bob"} {
+		seedUserWithRole(name, auth.RoleReader)
+	}
+	seedUserWithRole("erin", auth.RoleAdmin)
+
+	list := func(url string) (int, []string, string) {
+		rec := postRequest(server.GetUsers, "GET", url, nil, "", "")
+		assert.Equal(t, rec.Code, http.StatusOK)
+		page := struct {
+			Data  []models.User `json:"data"`
+			Total int           `json:"total"`
+			Links struct {
+				Next string `json:"next"`
+			} `json:"links"`
+		}{}
+		err := json.Unmarshal([]byte(rec.Body.String()), &page)
+		if err != nil {
+			t.Errorf("Cannot convert to json: %v", err)
+		}
+		names := []string{}
+		for _, user := range page.Data {
+			names = append(names, user.Username)
+		}
+		return page.Total, names, page.Links.Next
+	}
+	total, names, next := list("/users?sort=username&limit=2")
+	assert.Equal(t, total, 5)
+	assert.Equal(t, names, []string{"alice", "bob"})
+	_, names, _ = list(next)
+	assert.Equal(t, names, []string{"carol", "dave"})
+	_, names, _ = list("/users?sort=-username&limit=2")
+	assert.Equal(t, names, []string{"erin", "dave"})
+	total, names, next = list("/users?role=admin")
+	assert.Equal(t, total, 1)
+	assert.Equal(t, names, []string{"erin"})
+	assert.Equal(t, next, "")
+	assert.Equal(t, postRequest(server.GetUsers, "GET", "/users?sort=title", nil, "", "").Code, http.StatusBadRequest)
+}
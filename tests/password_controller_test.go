@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/mailer"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func TestPasswordReset(t *testing.T) {
+
+	mail := mailer.NewLogMailer(ioutil.Discard)
+	server.Mailer = mail
+
+	err := refreshUserTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	person, err := seedUser()
+	if err != nil {
+		log.Fatalf("Error Occurred seeding user %v\n", err)
+	}
+
+	forgot := func(inputJSON string) int {
+		req, err := http.NewRequest("POST", "/password/forgot", bytes.NewBufferString(inputJSON))
+		if err != nil {
+			t.Errorf("Error Occurred: %v\n", err)
+		}
+		rec := httptest.NewRecorder()
+		http.HandlerFunc(server.ForgotPassword).ServeHTTP(rec, req)
+		return rec.Code
+	}
+	reset := func(inputJSON string) int {
+		req, err := http.NewRequest("POST", "/password/reset", bytes.NewBufferString(inputJSON))
+		if err != nil {
+			t.Errorf("Error Occurred: %v\n", err)
+		}
+		rec := httptest.NewRecorder()
+		http.HandlerFunc(server.ResetPassword).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Unknown accounts get the same answer but no mail
+	assert.Equal(t, forgot(`{"email": "nobody@gmail.com"}`), http.StatusAccepted)
+	assert.Equal(t, len(mail.Sent()), 0)
+	assert.Equal(t, forgot(`{"email": "nobody"}`), http.StatusUnprocessableEntity)
+
+	// The mail links to the frontend's page, the API's route only takes a POST
+	server.PasswordResetURL = "https://medium.example.com/reset-password?ref=mail"
+	defer func() { server.PasswordResetURL = "" }()
+	assert.Equal(t, forgot(fmt.Sprintf(`{"email": "%s"}`, person.Email)), http.StatusAccepted)
+	msg, ok := mail.Last(person.Email)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, strings.Contains(msg.Body, "https://medium.example.com/reset-password?ref=mail&token="), true)
+	token := msg.Body[strings.Index(msg.Body, "token=")+len("token="):]
+	token = strings.Fields(token)[0]
+
+	assert.Equal(t, reset(`{"token": "wrong token", "password": "newpassword"}`), http.StatusUnprocessableEntity)
+	assert.Equal(t, reset(fmt.Sprintf(`{"token": "%s", "password": ""}`, token)), http.StatusUnprocessableEntity)
+	assert.Equal(t, reset(fmt.Sprintf(`{"token": "%s", "password": "newpassword"}`, token)), http.StatusOK)
+
+	// Tokens are single use
+	assert.Equal(t, reset(fmt.Sprintf(`{"token": "%s", "password": "otherpassword"}`, token)), http.StatusUnprocessableEntity)
+
+	_, err = server.SignIn(person.Email, "newpassword")
+	assert.Equal(t, err, nil)
+}
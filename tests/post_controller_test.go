@@ -29,7 +29,7 @@ func TestCreateAPost(t *testing.T) {
 	if err != nil {
 		log.Fatalf("Error occurred login: %v\n", err)
 	}
-	tokenString := fmt.Sprintf("Bearer %v", token)
+	tokenString := fmt.Sprintf("Bearer %v", token.AccessToken)
 
 	samples := []struct {
 		inputJSON    string
@@ -121,7 +121,7 @@ func TestCreateAPost(t *testing.T) {
 		assert.Equal(t, rec.Code, v.statusCode)
 		if v.statusCode == 201 {
 			assert.Equal(t, responseMap["title"], v.title)
-			assert.Equal(t, responseMap["content"], v.content)
+			assert.Equal(t, responseMap["content_markdown"], v.content)
 			assert.Equal(t, responseMap["author_id"], float64(v.authorId))
 		}
 		if v.statusCode == 401 || v.statusCode == 422 || v.statusCode == 500 && v.errorMessage != "" {
@@ -150,11 +150,13 @@ func TestGetPosts(t *testing.T) {
 	handler := http.HandlerFunc(server.GetPosts)
 	handler.ServeHTTP(rec, req)
 
-	var posts []models.Post
-	err = json.Unmarshal([]byte(rec.Body.String()), &posts)
+	var page struct {
+		Data []models.Post `json:"data"`
+	}
+	err = json.Unmarshal([]byte(rec.Body.String()), &page)
 
 	assert.Equal(t, rec.Code, http.StatusOK)
-	assert.Equal(t, len(posts), 2)
+	assert.Equal(t, len(page.Data), 2)
 }
 
 func TestAPost(t *testing.T) {
@@ -212,7 +214,7 @@ func TestAPost(t *testing.T) {
 
 		if v.statusCode == 200 {
 			assert.Equal(t, post.Title, responseMap["title"])
-			assert.Equal(t, post.Content, responseMap["content"])
+			assert.Equal(t, post.Content, responseMap["content_markdown"])
 			assert.Equal(t, float64(post.AuthorID), responseMap["author_id"])
 		}
 	}
@@ -248,7 +250,7 @@ func TestModifyPost(t *testing.T) {
 	if err != nil {
 		log.Fatalf("Error Occurred cannot login: %v", token)
 	}
-	tokenString := fmt.Sprintf("Bearer %v", token)
+	tokenString := fmt.Sprintf("Bearer %v", token.AccessToken)
 
 	// Get the first post
 	for _, post := range posts {
@@ -362,7 +364,7 @@ func TestModifyPost(t *testing.T) {
 		assert.Equal(t, rec.Code, v.statusCode)
 		if v.statusCode == 200 {
 			assert.Equal(t, responseMap["title"], v.title)
-			assert.Equal(t, responseMap["content"], v.content)
+			assert.Equal(t, responseMap["content_markdown"], v.content)
 			assert.Equal(t, responseMap["author_id"], float64(v.author_id)) //just to match the type of the json we receive thats why we used float64
 		}
 		if v.statusCode == 401 || v.statusCode == 422 || v.statusCode == 500 && v.errorMessage != "" {
@@ -400,7 +402,7 @@ func TestRemovePost(t *testing.T) {
 	if err != nil {
 		log.Fatalf("cannot login: %v\n", err)
 	}
-	tokenString := fmt.Sprintf("Bearer %v", token)
+	tokenString := fmt.Sprintf("Bearer %v", token.AccessToken)
 
 	// Get the second post
 	for _, post := range posts {
@@ -17,7 +17,7 @@ func TestAllPosts(t *testing.T) {
 	if err != nil {
 		log.Fatalf("Error occurred while seeding user and post table %v\n", err)
 	}
-	posts, err := post.AllPosts(server.DB)
+	posts, _, err := post.AllPosts(server.DB, models.PostFilter{Status: models.PostStatusPublished}, models.Paging{})
 	if err != nil {
 		t.Errorf("Error occurred while fetching posts: %v\n", err)
 		return
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+// postPage is a page of posts as listings answer with it
+type postPage struct {
+	Data       []models.Post `json:"data"`
+	Total      int           `json:"total"`
+	NextCursor string        `json:"next_cursor"`
+	PrevCursor string        `json:"prev_cursor"`
+	Links      struct {
+		Next string `json:"next"`
+		Prev string `json:"prev"`
+	} `json:"links"`
+}
+
+func countPosts(rec *httptest.ResponseRecorder) int {
+	page := postPage{}
+	err := json.Unmarshal([]byte(rec.Body.String()), &page)
+	if err != nil {
+		log.Fatalf("Cannot convert to json: %v", err)
+	}
+	return len(page.Data)
+}
+
+func TestPostStatus(t *testing.T) {
+
+	err := refreshUserAndPostTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	author := seedUserWithRole("author", auth.RoleAuthor)
+	other := seedUserWithRole("other", auth.RoleAuthor)
+	editor := seedUserWithRole("editor", auth.RoleEditor)
+	tokens := signIn(author, other, editor)
+
+	// New posts start out as drafts
+	inputJSON := fmt.Sprintf(`{"title": "Draft title", "content": "Draft content", "author_id": %d}`, author.ID)
+	rec := postRequest(server.CreatePost, "POST", "/posts", nil, inputJSON, tokens[author.ID])
+	assert.Equal(t, rec.Code, http.StatusCreated)
+	created := models.Post{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &created)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, created.Status, models.PostStatusDraft)
+	assert.Equal(t, created.PublishedAt, (*time.Time)(nil))
+	vars := map[string]string{"id": strconv.Itoa(int(created.ID))}
+
+	// Drafts are only visible to their author and editors
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts", nil, "", "")), 0)
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts?status=draft", nil, "", tokens[author.ID])), 1)
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts?status=draft", nil, "", tokens[other.ID])), 0)
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts?status=draft", nil, "", tokens[editor.ID])), 1)
+	assert.Equal(t, postRequest(server.GetPosts, "GET", "/posts?status=draft", nil, "", "").Code, http.StatusUnauthorized)
+	assert.Equal(t, postRequest(server.GetPost, "GET", "/posts", vars, "", "").Code, http.StatusNotFound)
+	assert.Equal(t, postRequest(server.GetPost, "GET", "/posts", vars, "", tokens[other.ID]).Code, http.StatusNotFound)
+	assert.Equal(t, postRequest(server.GetPost, "GET", "/posts", vars, "", tokens[author.ID]).Code, http.StatusOK)
+
+	// Only the author (or an editor) publishes
+	assert.Equal(t, postRequest(server.PublishPost, "POST", "/posts/publish", vars, "", tokens[other.ID]).Code, http.StatusUnauthorized)
+	assert.Equal(t, postRequest(server.PublishPost, "POST", "/posts/publish", vars, `{"status": "archived"}`, tokens[author.ID]).Code, http.StatusUnprocessableEntity)
+	rec = postRequest(server.PublishPost, "POST", "/posts/publish", vars, "", tokens[author.ID])
+	assert.Equal(t, rec.Code, http.StatusOK)
+	published := models.Post{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &published)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, published.Status, models.PostStatusPublished)
+	assert.NotEqual(t, published.PublishedAt, (*time.Time)(nil))
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts", nil, "", "")), 1)
+	assert.Equal(t, postRequest(server.GetPost, "GET", "/posts", vars, "", "").Code, http.StatusOK)
+
+	// Unlisted posts can be read, but are not listed
+	rec = postRequest(server.PublishPost, "POST", "/posts/publish", vars, `{"status": "unlisted"}`, tokens[author.ID])
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts", nil, "", "")), 0)
+	assert.Equal(t, postRequest(server.GetPost, "GET", "/posts", vars, "", "").Code, http.StatusOK)
+
+	// Archiving takes the post down again, but keeps its publication date
+	rec = postRequest(server.UnpublishPost, "POST", "/posts/unpublish", vars, `{"status": "archived"}`, tokens[editor.ID])
+	assert.Equal(t, rec.Code, http.StatusOK)
+	archived := models.Post{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &archived)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, archived.Status, models.PostStatusArchived)
+	assert.Equal(t, archived.PublishedAt.Unix(), published.PublishedAt.Unix())
+	assert.Equal(t, postRequest(server.GetPost, "GET", "/posts", vars, "", "").Code, http.StatusNotFound)
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts?status=archived", nil, "", tokens[author.ID])), 1)
+}
+
+// legacyPost is a post as it was stored before posts had a status
+type legacyPost struct {
+	ID        uint64 `gorm:"primary_key;auto_increment"`
+	Title     string `gorm:"size:255;not null;unique"`
+	Content   string `gorm:"size:255;not null;"`
+	AuthorID  uint32 `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+}
+
+func (legacyPost) TableName() string {
+	return "posts"
+}
+
+func TestMigrateStatus(t *testing.T) {
+
+	err := refreshUserAndPostTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	author := seedUserWithRole("author", auth.RoleAuthor)
+	err = server.DB.DropTable(&models.Post{}).CreateTable(&legacyPost{}).Error
+	if err != nil {
+		log.Fatal(err)
+	}
+	old := legacyPost{Title: "Before statuses", Content: "Content", AuthorID: author.ID}
+	err = server.DB.Create(&old).Error
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Posts from before statuses were public, they stay published
+	err = models.MigrateStatus(server.DB)
+	assert.Equal(t, err, nil)
+	err = server.DB.AutoMigrate(&models.Post{}).Error
+	if err != nil {
+		log.Fatal(err)
+	}
+	post := models.Post{}
+	err = server.DB.Model(&models.Post{}).Where("id = ?", old.ID).Take(&post).Error
+	assert.Equal(t, err, nil)
+	assert.Equal(t, post.Status, models.PostStatusPublished)
+	assert.Equal(t, post.PublishedAt != nil, true)
+
+	// New posts saved without a status are drafts, and the next start leaves them so
+	draft := models.Post{Title: "After statuses", Content: "Content", AuthorID: author.ID}
+	err = server.DB.Create(&draft).Error
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = models.MigrateStatus(server.DB)
+	assert.Equal(t, err, nil)
+	post = models.Post{}
+	err = server.DB.Model(&models.Post{}).Where("id = ?", draft.ID).Take(&post).Error
+	assert.Equal(t, err, nil)
+	assert.Equal(t, post.Status, models.PostStatusDraft)
+	assert.Equal(t, post.PublishedAt == nil, true)
+}
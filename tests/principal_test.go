@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/middlewares"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func TestPrincipalInContext(t *testing.T) {
+
+	err := refreshUserTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	person := seedUserWithRole("editor", auth.RoleEditor)
+	tokens, err := server.SignIn(person.Email, "password")
+	if err != nil {
+		log.Fatalf("Error occurred login: %v\n", err)
+	}
+
+	var principal *auth.Principal
+	handler := middlewares.SetMiddlewareAuthentication(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = auth.PrincipalFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, requestWithToken(tokens.AccessToken))
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.NotEqual(t, principal, nil)
+	assert.Equal(t, principal.UserID, person.ID)
+	assert.Equal(t, principal.Role, auth.RoleEditor)
+	assert.Equal(t, principal.AuthMethod, auth.AuthMethodJWT)
+	assert.Equal(t, principal.HasScope(auth.ScopeUsersWrite), true)
+
+	// A revoked token never reaches the handler
+	err = auth.RevokeToken(principal)
+	assert.Equal(t, err, nil)
+	principal = nil
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, requestWithToken(tokens.AccessToken))
+	assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	assert.Equal(t, principal, nil)
+}
+
+func TestDeleteUserRequiresOwnerOrPermission(t *testing.T) {
+
+	err := refreshUserTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	victim := seedUserWithRole("victim", auth.RoleAuthor)
+	samples := []struct {
+		username   string
+		role       string
+		statusCode int
+	}{
+		{username: "author", role: auth.RoleAuthor, statusCode: 401},
+		{username: "editor", role: auth.RoleEditor, statusCode: 401},
+		{username: "admin", role: auth.RoleAdmin, statusCode: 204},
+	}
+
+	for _, v := range samples {
+		person := seedUserWithRole(v.username, v.role)
+		tokens, err := server.SignIn(person.Email, "password")
+		if err != nil {
+			log.Fatalf("Error occurred login: %v\n", err)
+		}
+
+		req, err := http.NewRequest("DELETE", "/users", nil)
+		if err != nil {
+			t.Errorf("Error Occurred: %v\n", err)
+		}
+		req = mux.SetURLVars(req, map[string]string{"id": strconv.Itoa(int(victim.ID))})
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", tokens.AccessToken))
+
+		rec := httptest.NewRecorder()
+		middlewares.SetMiddlewareAuthentication(server.DeleteUser).ServeHTTP(rec, req)
+
+		assert.Equal(t, rec.Code, v.statusCode)
+	}
+}
@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/controllers"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/utils/linediff"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func TestLineDiff(t *testing.T) {
+	lines := linediff.Diff("one\ntwo\nthree", "one\n2\nthree\nfour")
+	assert.Equal(t, lines, []linediff.Line{
+		{Op: linediff.OpEqual, Text: "one"},
+		{Op: linediff.OpDelete, Text: "two"},
+		{Op: linediff.OpInsert, Text: "2"},
+		{Op: linediff.OpEqual, Text: "three"},
+		{Op: linediff.OpInsert, Text: "four"},
+	})
+	assert.Equal(t, linediff.Diff("same\ntext", "same\ntext"), []linediff.Line{{Op: linediff.OpEqual, Text: "same"}, {Op: linediff.OpEqual, Text: "text"}})
+	assert.Equal(t, linediff.Diff("", "new"), []linediff.Line{{Op: linediff.OpInsert, Text: "new"}})
+}
+
+func TestPostRevisions(t *testing.T) {
+
+	err := refreshUserAndPostTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	author := seedUserWithRole("author", auth.RoleAuthor)
+	other := seedUserWithRole("other", auth.RoleAuthor)
+	tokens := signIn(author, other)
+
+	inputJSON := fmt.Sprintf(`{"title": "Draft", "content": "one\ntwo\nthree", "author_id": %d}`, author.ID)
+	rec := postRequest(server.CreatePost, "POST", "/posts", nil, inputJSON, tokens[author.ID])
+	assert.Equal(t, rec.Code, http.StatusCreated)
+	post := models.Post{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &post)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	id := strconv.Itoa(int(post.ID))
+
+	update := func(title, content string) {
+		inputJSON := fmt.Sprintf(`{"title": "%s", "content": "%s", "author_id": %d}`, title, content, author.ID)
+		rec := postRequest(server.UpdatePost, "PUT", "/posts", map[string]string{"id": id}, inputJSON, tokens[author.ID])
+		assert.Equal(t, rec.Code, http.StatusOK)
+	}
+	revisions := func() []models.PostRevision {
+		rec := postRequest(server.GetRevisions, "GET", "/posts/revisions", map[string]string{"id": id}, "", tokens[author.ID])
+		assert.Equal(t, rec.Code, http.StatusOK)
+		list := []models.PostRevision{}
+		err := json.Unmarshal([]byte(rec.Body.String()), &list)
+		if err != nil {
+			t.Errorf("Cannot convert to json: %v", err)
+		}
+		return list
+	}
+	update("Final", `one\n2\nthree`)
+	update("Final", `one\n2\nthree\nfour`)
+
+	list := revisions()
+	assert.Equal(t, len(list), 3)
+	assert.Equal(t, list[0].Number, 3)
+	assert.Equal(t, list[2].Title, "Draft")
+	assert.Equal(t, list[2].Content, "one\ntwo\nthree")
+
+	// Nobody else gets to look at old versions
+	assert.Equal(t, postRequest(server.GetRevisions, "GET", "/posts/revisions", map[string]string{"id": id}, "", tokens[other.ID]).Code, http.StatusUnauthorized)
+
+	rec = postRequest(server.GetRevision, "GET", "/posts/revisions", map[string]string{"id": id, "rev": "1"}, "", tokens[author.ID])
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, postRequest(server.GetRevision, "GET", "/posts/revisions", map[string]string{"id": id, "rev": "9"}, "", tokens[author.ID]).Code, http.StatusNotFound)
+
+	diff := func(rev, against string) controllers.RevisionDiff {
+		url := "/posts/revisions/diff"
+		if against != "" {
+			url += "?against=" + against
+		}
+		rec := postRequest(server.DiffRevision, "GET", url, map[string]string{"id": id, "rev": rev}, "", tokens[author.ID])
+		assert.Equal(t, rec.Code, http.StatusOK)
+		d := controllers.RevisionDiff{}
+		err := json.Unmarshal([]byte(rec.Body.String()), &d)
+		if err != nil {
+			t.Errorf("Cannot convert to json: %v", err)
+		}
+		return d
+	}
+	d := diff("3", "")
+	assert.Equal(t, d.From, 2)
+	assert.Equal(t, d.To, 3)
+	assert.Equal(t, d.Title, []linediff.Line{{Op: linediff.OpEqual, Text: "Final"}})
+	inserted := []string{}
+	for _, line := range d.Content {
+		if line.Op == linediff.OpInsert {
+			inserted = append(inserted, line.Text)
+		}
+	}
+	assert.Equal(t, inserted[len(inserted)-1], "four")
+
+	d = diff("1", "2")
+	assert.Equal(t, d.From, 1)
+	assert.Equal(t, d.To, 2)
+	assert.Equal(t, d.Content[0], linediff.Line{Op: linediff.OpEqual, Text: "one"})
+	assert.Equal(t, d.Content[1], linediff.Line{Op: linediff.OpDelete, Text: "two"})
+	assert.Equal(t, d.Content[2], linediff.Line{Op: linediff.OpInsert, Text: "2"})
+
+	// Restoring saves the old version as the newest revision
+	rec = postRequest(server.RestoreRevision, "POST", "/posts/revisions/restore", map[string]string{"id": id, "rev": "1"}, "", tokens[other.ID])
+	assert.Equal(t, rec.Code, http.StatusUnauthorized)
+	rec = postRequest(server.RestoreRevision, "POST", "/posts/revisions/restore", map[string]string{"id": id, "rev": "1"}, "", tokens[author.ID])
+	assert.Equal(t, rec.Code, http.StatusOK)
+	restored := models.Post{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &restored)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, restored.Title, "Draft")
+	assert.Equal(t, restored.Content, "one\ntwo\nthree")
+	assert.Equal(t, restored.ContentHTML, "<p>one\ntwo\nthree</p>\n")
+	list = revisions()
+	assert.Equal(t, len(list), 4)
+	assert.Equal(t, list[0].Title, "Draft")
+
+	// The retention policy prunes the oldest revisions but always keeps the newest
+	server.RevisionRetention = models.RevisionRetention{Keep: 2}
+	defer func() { server.RevisionRetention = models.RevisionRetention{} }()
+	update("Final", "five")
+	list = revisions()
+	assert.Equal(t, len(list), 2)
+	assert.Equal(t, list[0].Number, 5)
+	assert.Equal(t, list[1].Number, 4)
+
+	server.RevisionRetention = models.RevisionRetention{MaxAge: time.Hour}
+	server.Now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	defer func() { server.Now = nil }()
+	update("Final", "six")
+	list = revisions()
+	assert.Equal(t, len(list), 1)
+	assert.Equal(t, list[0].Number, 6)
+}
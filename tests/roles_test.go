@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/middlewares"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func TestEditorCanDeleteAnyPost(t *testing.T) {
+
+	post, err := seedOneUserAndOnePost()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	samples := []struct {
+		username   string
+		role       string
+		statusCode int
+	}{
+		{username: "reader", role: auth.RoleReader, statusCode: 401},
+		{username: "author", role: auth.RoleAuthor, statusCode: 401},
+		{username: "editor", role: auth.RoleEditor, statusCode: 204},
+	}
+
+	for _, v := range samples {
+		person := seedUserWithRole(v.username, v.role)
+		tokens, err := server.SignIn(person.Email, "password")
+		if err != nil {
+			log.Fatalf("Error occurred login: %v\n", err)
+		}
+
+		req, err := http.NewRequest("DELETE", "/posts", nil)
+		if err != nil {
+			t.Errorf("Error Occurred: %v\n", err)
+		}
+		req = mux.SetURLVars(req, map[string]string{"id": strconv.Itoa(int(post.ID))})
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", tokens.AccessToken))
+
+		rec := httptest.NewRecorder()
+		handler := http.HandlerFunc(server.DeletePost)
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, rec.Code, v.statusCode)
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+
+	err := refreshUserTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	samples := []struct {
+		username   string
+		role       string
+		statusCode int
+	}{
+		{username: "reader", role: auth.RoleReader, statusCode: 403},
+		{username: "author", role: auth.RoleAuthor, statusCode: 200},
+	}
+
+	handler := middlewares.RequirePermission(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, auth.PermPostsCreate)
+
+	for _, v := range samples {
+		person := seedUserWithRole(v.username, v.role)
+		tokens, err := server.SignIn(person.Email, "password")
+		if err != nil {
+			log.Fatalf("Error occurred login: %v\n", err)
+		}
+
+		req, err := http.NewRequest("POST", "/posts", nil)
+		if err != nil {
+			t.Errorf("Error Occurred: %v\n", err)
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", tokens.AccessToken))
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, rec.Code, v.statusCode)
+	}
+}
@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/scheduler"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func TestScheduledPublishing(t *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+	clock := func() time.Time { return now }
+	server.Now = clock
+	defer func() { server.Now = nil }()
+
+	err := refreshUserAndPostTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	author := seedUserWithRole("author", auth.RoleAuthor)
+	token := signIn(author)[author.ID]
+
+	inputJSON := fmt.Sprintf(`{"title": "Later", "content": "Not yet", "author_id": %d}`, author.ID)
+	rec := postRequest(server.CreatePost, "POST", "/posts", nil, inputJSON, token)
+	assert.Equal(t, rec.Code, http.StatusCreated)
+	post := models.Post{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &post)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	vars := map[string]string{"id": strconv.Itoa(int(post.ID))}
+
+	// Publishing with a time in the future schedules the post
+	publishAt := now.Add(time.Hour).UTC()
+	inputJSON = fmt.Sprintf(`{"publish_at": "%s"}`, publishAt.Format(time.RFC3339))
+	rec = postRequest(server.PublishPost, "POST", "/posts/publish", vars, inputJSON, token)
+	assert.Equal(t, rec.Code, http.StatusOK)
+	scheduled := models.Post{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &scheduled)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, scheduled.Status, models.PostStatusPublished)
+	assert.Equal(t, scheduled.PublishAt.Equal(publishAt), true)
+	assert.Equal(t, scheduled.PublishedAt, (*time.Time)(nil))
+
+	// It stays hidden from everyone but its author until it is due
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts", nil, "", "")), 0)
+	assert.Equal(t, postRequest(server.GetPost, "GET", "/posts", vars, "", "").Code, http.StatusNotFound)
+	assert.Equal(t, postRequest(server.GetPost, "GET", "/posts", vars, "", token).Code, http.StatusOK)
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts?scheduled=true", nil, "", token)), 1)
+
+	announced := []uint64{}
+	publisher := scheduler.New(server.DB, time.Minute)
+	publisher.Now = clock
+	publisher.OnPublish = func(post *models.Post) { announced = append(announced, post.ID) }
+
+	published, err := publisher.RunOnce()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, published, 0)
+
+	// Once due it is visible right away, the scheduler then publishes it and runs the side effects once
+	now = now.Add(2 * time.Hour)
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts", nil, "", "")), 1)
+
+	due := models.Post{}
+	duePosts, err := due.DuePosts(server.DB, now, 10)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(*duePosts), 1)
+	stale := (*duePosts)[0]
+
+	published, err = publisher.RunOnce()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, published, 1)
+	assert.Equal(t, announced, []uint64{post.ID})
+
+	published, err = publisher.RunOnce()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, published, 0)
+
+	// Another instance that picked up the same post loses the race
+	won, err := stale.PublishDuePost(server.DB)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, won, false)
+
+	livePost := models.Post{}
+	_, err = livePost.SinglePost(server.DB, post.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, livePost.PublishAt, (*time.Time)(nil))
+	assert.Equal(t, livePost.PublishedAt.Equal(publishAt), true)
+}
+
+func TestSchedulerStartStop(t *testing.T) {
+
+	post, err := seedOneUserAndOnePost()
+	if err != nil {
+		log.Fatal(err)
+	}
+	publishAt := time.Now().Add(-time.Minute)
+	err = server.DB.Model(&models.Post{}).Where("id = ?", post.ID).UpdateColumns(map[string]interface{}{"publish_at": publishAt, "published_at": nil}).Error
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	announced := make(chan uint64, 1)
+	publisher := scheduler.New(server.DB, time.Millisecond*10)
+	publisher.OnPublish = func(post *models.Post) { announced <- post.ID }
+	publisher.Start()
+
+	select {
+	case id := <-announced:
+		assert.Equal(t, id, post.ID)
+	case <-time.After(time.Second * 5):
+		t.Error("The scheduler did not publish the due post")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		publisher.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second * 5):
+		t.Error("The scheduler did not stop")
+	}
+}
@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/utils/search"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+type searchPage struct {
+	Data       []models.SearchHit `json:"data"`
+	Total      int                `json:"total"`
+	NextCursor string             `json:"next_cursor"`
+	PrevCursor string             `json:"prev_cursor"`
+}
+
+func TestSearch(t *testing.T) {
+	engine := server.SearchEngine
+	defer func() { server.SearchEngine = engine }()
+
+	// The engine of the test database, and the index kept in memory where the database has no text search of its own
+	engines := map[string]func() models.SearchEngine{
+		"database": func() models.SearchEngine { return models.NewSearchEngine(server.DB) },
+		"index":    func() models.SearchEngine { return models.NewIndexSearch(server.DB) },
+	}
+	for name, newEngine := range engines {
+		t.Run(name, func(t *testing.T) {
+			err := refreshUserAndPostTable()
+			if err != nil {
+				log.Fatal(err)
+			}
+			err = models.MigrateSearch(server.DB)
+			if err != nil {
+				log.Fatal(err)
+			}
+			server.SearchEngine = newEngine()
+			testSearch(t)
+		})
+	}
+}
+
+func testSearch(t *testing.T) {
+	author := seedUserWithRole("gopher", auth.RoleAuthor)
+	seedUserWithRole("reader", auth.RoleReader)
+	later := time.Now().Add(time.Hour)
+	posts := []models.Post{
+		{Title: "Go modules explained", Content: "How modules work, from go.mod to the proxy", Status: models.PostStatusPublished},
+		{Title: "Cooking pasta", Content: "Boil the water, then go and salt it <script>", Status: models.PostStatusPublished},
+		{Title: "Secret go plans", Content: "Still a draft", Status: models.PostStatusDraft},
+		{Title: "Go next week", Content: "Not out yet", Status: models.PostStatusPublished, PublishAt: &later},
+		{Title: "Tom & Jerry", Content: "Don't panic", Status: models.PostStatusPublished},
+	}
+	for i := range posts {
+		posts[i].AuthorID = author.ID
+		posts[i].Prepare()
+		_, err := posts[i].SavePost(server.DB)
+		if err != nil {
+			log.Fatalf("Cannot save post: %v", err)
+		}
+	}
+
+	find := func(url string) searchPage {
+		rec := postRequest(server.Search, "GET", url, nil, "", "")
+		assert.Equal(t, rec.Code, http.StatusOK)
+		page := searchPage{}
+		err := json.Unmarshal([]byte(rec.Body.String()), &page)
+		if err != nil {
+			t.Errorf("Cannot convert to json: %v", err)
+		}
+		return page
+	}
+
+	// Only what anyone may read turns up, matches in the title rank higher
+	page := find("/search?q=Go&type=posts")
+	assert.Equal(t, page.Total, 2)
+	assert.Equal(t, page.Data[0].Post.Title, "Go modules explained")
+	assert.Equal(t, page.Data[0].Title, "<mark>Go</mark> modules explained")
+	assert.Equal(t, page.Data[0].Kind, models.SearchKindPost)
+	assert.Equal(t, page.Data[1].Snippet, "Boil the water, then <mark>go</mark> and salt it &lt;script&gt;")
+	assert.Equal(t, page.Data[0].Rank > page.Data[1].Rank, true)
+
+	// Short words and stopwords match too, MySQL leaves them out of its FULLTEXT indexes
+	assert.Equal(t, find("/search?q=go+modules").Total, 1)
+	assert.Equal(t, find("/search?q=how+modules").Total, 1)
+
+	// Titles are searched and highlighted as they were written, not as they are stored
+	page = find("/search?q=jerry")
+	assert.Equal(t, page.Total, 1)
+	assert.Equal(t, page.Data[0].Title, "Tom &amp; <mark>Jerry</mark>")
+	assert.Equal(t, page.Data[0].Snippet, "Don&#39;t panic")
+	assert.Equal(t, find("/search?q=amp").Total, 0)
+
+	// Every word has to match, plurals match the singular
+	page = find("/search?q=module+proxy")
+	assert.Equal(t, page.Total, 1)
+	assert.Equal(t, page.Data[0].Title, "Go <mark>modules</mark> explained")
+	assert.Equal(t, find("/search?q=module+pasta").Total, 0)
+
+	page = find("/search?q=gopher")
+	assert.Equal(t, page.Total, 1)
+	assert.Equal(t, page.Data[0].Kind, models.SearchKindUser)
+	assert.Equal(t, page.Data[0].User.ID, author.ID)
+	assert.Equal(t, page.Data[0].Title, "<mark>gopher</mark>")
+	assert.Equal(t, find("/search?q=gopher&type=posts").Total, 0)
+
+	// Anyone may search, users and the authors of posts come without the rest of their account
+	for _, url := range []string{"/search?q=gopher&type=users", "/search?q=modules&type=posts"} {
+		rec := postRequest(server.Search, "GET", url, nil, "", "")
+		assert.Equal(t, strings.Contains(rec.Body.String(), "password"), false)
+		assert.Equal(t, strings.Contains(rec.Body.String(), "@gmail.com"), false)
+	}
+
+	// A page at a time, the cursors count positions
+	page = find("/search?q=go&limit=1")
+	assert.Equal(t, page.Total, 2)
+	assert.Equal(t, page.PrevCursor, "")
+	second := find("/search?q=go&limit=1&cursor=" + page.NextCursor)
+	assert.Equal(t, second.Data[0].Post.Title, "Cooking pasta")
+	assert.Equal(t, second.NextCursor, "")
+	assert.Equal(t, find("/search?q=go&limit=1&cursor=" + second.PrevCursor).Data[0].ID, page.Data[0].ID)
+
+	// Changes show up in the next search
+	_, err := posts[1].DeletePost(server.DB, posts[1].ID, author.ID)
+	if err != nil {
+		log.Fatalf("Cannot delete post: %v", err)
+	}
+	assert.Equal(t, find("/search?q=pasta").Total, 0)
+
+	assert.Equal(t, postRequest(server.Search, "GET", "/search", nil, "", "").Code, http.StatusBadRequest)
+	assert.Equal(t, postRequest(server.Search, "GET", "/search?q=go&type=tags", nil, "", "").Code, http.StatusBadRequest)
+	assert.Equal(t, postRequest(server.Search, "GET", "/search?q=go&sort=title", nil, "", "").Code, http.StatusBadRequest)
+	assert.Equal(t, postRequest(server.Search, "GET", "/search?q=go&limit=101", nil, "", "").Code, http.StatusBadRequest)
+}
+
+func TestSearchIndex(t *testing.T) {
+	assert.Equal(t, search.Terms("The Stories of Gophers, and a Gopher"), []string{"story", "gopher"})
+
+	index := search.NewIndex()
+	index.Add(search.Key{Kind: "post", ID: 1}, search.Field{Text: "Testing in Go", Weight: 2}, search.Field{Text: "Table driven tests"})
+	index.Add(search.Key{Kind: "post", ID: 2}, search.Field{Text: "Benchmarks", Weight: 2}, search.Field{Text: "Go tests can measure speed"})
+	matches := index.Search("go tests")
+	assert.Equal(t, len(matches), 2)
+	assert.Equal(t, matches[0].Key.ID, uint64(1))
+	index.Remove(search.Key{Kind: "post", ID: 1})
+	assert.Equal(t, index.Search("go tests")[0].Key.ID, uint64(2))
+	assert.Equal(t, len(index.Search("table")), 0)
+	assert.Equal(t, index.Len(), 1)
+
+	long := "one two three four five six seven eight nine ten match eleven twelve thirteen fourteen fifteen"
+	assert.Equal(t, search.Highlight(long, "match", 5), "… ten <mark>match</mark> eleven twelve thirteen …")
+}
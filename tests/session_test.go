@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/middlewares"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func sessionRequest(method, url string, cookies []*http.Cookie, csrfToken string) *http.Request {
+	req, err := http.NewRequest(method, url, bytes.NewBufferString(""))
+	if err != nil {
+		log.Fatalf("Error Occurred: %v\n", err)
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	if csrfToken != "" {
+		req.Header.Set(auth.CSRFHeaderName, csrfToken)
+	}
+	return req
+}
+
+func TestCookieSession(t *testing.T) {
+
+	config := auth.DefaultSessionConfig()
+	config.Cookies, config.QueryTokens, config.Secure = true, false, false
+	auth.SetSessionConfig(config)
+	defer auth.SetSessionConfig(auth.DefaultSessionConfig())
+
+	err := refreshUserTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	person, err := seedUser()
+	if err != nil {
+		log.Fatalf("Error Occurred seeding user %v\n", err)
+	}
+
+	// Login hands out cookies instead of tokens
+	inputJSON := fmt.Sprintf(`{"email": "%s", "password": "Password", "session": true}`, person.Email)
+	req, err := http.NewRequest("POST", "/login", bytes.NewBufferString(inputJSON))
+	if err != nil {
+		t.Errorf("Error Occurred: %v\n", err)
+	}
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(server.Login).ServeHTTP(rec, req)
+	assert.Equal(t, rec.Code, http.StatusOK)
+
+	responseMap := make(map[string]interface{})
+	err = json.Unmarshal([]byte(rec.Body.String()), &responseMap)
+	if err != nil {
+		t.Errorf("Error occurred converting to json: %v", err)
+	}
+	assert.Equal(t, responseMap["access_token"], nil)
+	assert.Equal(t, responseMap["refresh_token"], nil)
+	csrfToken, _ := responseMap["csrf_token"].(string)
+	assert.NotEqual(t, csrfToken, "")
+
+	cookies := rec.Result().Cookies()
+	assert.Equal(t, len(cookies), 3)
+	for _, cookie := range cookies {
+		assert.Equal(t, cookie.HttpOnly, cookie.Name != auth.CSRFCookieName)
+		assert.Equal(t, cookie.SameSite, http.SameSiteLaxMode)
+	}
+
+	ok := middlewares.SetMiddlewareAuthentication(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	samples := []struct {
+		method     string
+		csrfToken  string
+		statusCode int
+	}{
+		{method: "GET", csrfToken: "", statusCode: 200},
+		{method: "POST", csrfToken: "", statusCode: 403},
+		{method: "POST", csrfToken: "forged", statusCode: 403},
+		{method: "POST", csrfToken: csrfToken, statusCode: 200},
+	}
+	for _, v := range samples {
+		rec = httptest.NewRecorder()
+		ok.ServeHTTP(rec, sessionRequest(v.method, "/", cookies, v.csrfToken))
+		assert.Equal(t, rec.Code, v.statusCode)
+	}
+
+	// Query string tokens are switched off
+	tokens, err := server.SignIn(person.Email, "Password")
+	if err != nil {
+		log.Fatalf("Error occurred login: %v\n", err)
+	}
+	rec = httptest.NewRecorder()
+	ok.ServeHTTP(rec, sessionRequest("GET", "/?token="+tokens.AccessToken, nil, ""))
+	assert.Equal(t, rec.Code, http.StatusUnauthorized)
+
+	// The refresh cookie rotates the session, but only with the CSRF token
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(server.RefreshToken).ServeHTTP(rec, sessionRequest("POST", "/token/refresh", cookies, ""))
+	assert.Equal(t, rec.Code, http.StatusForbidden)
+
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(server.RefreshToken).ServeHTTP(rec, sessionRequest("POST", "/token/refresh", cookies, csrfToken))
+	assert.Equal(t, rec.Code, http.StatusOK)
+	rotated := rec.Result().Cookies()
+	assert.Equal(t, len(rotated), 3)
+
+	responseMap = make(map[string]interface{})
+	err = json.Unmarshal([]byte(rec.Body.String()), &responseMap)
+	if err != nil {
+		t.Errorf("Error occurred converting to json: %v", err)
+	}
+	csrfToken, _ = responseMap["csrf_token"].(string)
+
+	// Logout ends the session and clears the cookies
+	rec = httptest.NewRecorder()
+	middlewares.SetMiddlewareAuthentication(server.Logout).ServeHTTP(rec, sessionRequest("POST", "/logout", rotated, csrfToken))
+	assert.Equal(t, rec.Code, http.StatusNoContent)
+	for _, cookie := range rec.Result().Cookies() {
+		assert.Equal(t, cookie.MaxAge, -1)
+	}
+
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(server.RefreshToken).ServeHTTP(rec, sessionRequest("POST", "/token/refresh", rotated, csrfToken))
+	assert.Equal(t, rec.Code, http.StatusUnauthorized)
+}
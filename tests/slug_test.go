@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func TestMakeSlug(t *testing.T) {
+	samples := []struct {
+		title string
+		slug  string
+	}{
+		{title: "Hello World", slug: "hello-world"},
+		{title: "  Crème brûlée, à la carte!  ", slug: "creme-brulee-a-la-carte"},
+		{title: "Привет мир", slug: "privet-mir"},
+		{title: "!!!", slug: "post"},
+	}
+	for _, v := range samples {
+		assert.Equal(t, models.MakeSlug(v.title), v.slug)
+	}
+}
+
+func TestPostSlugs(t *testing.T) {
+
+	err := refreshUserAndPostTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	author := seedUserWithRole("author", auth.RoleAuthor)
+	token := signIn(author)[author.ID]
+
+	newPost := func(title string) models.Post {
+		return seedPost(author, title, models.PostStatusPublished)
+	}
+	first := newPost("Hello World")
+	second := newPost("Hello, World")
+	assert.Equal(t, first.Slug, "hello-world")
+	assert.Equal(t, second.Slug, "hello-world-2")
+
+	rec := postRequest(server.GetPostBySlug, "GET", "/posts/by-slug/hello-world-2", map[string]string{"slug": "hello-world-2"}, "", "")
+	assert.Equal(t, rec.Code, http.StatusOK)
+	found := models.Post{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &found)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, found.ID, second.ID)
+
+	// Renaming a post moves it to a new slug, the old one redirects
+	rename := func(post models.Post, title string) models.Post {
+		inputJSON := fmt.Sprintf(`{"title": "%s", "content": "Content", "author_id": %d}`, title, author.ID)
+		rec := postRequest(server.UpdatePost, "PUT", "/posts", map[string]string{"id": strconv.Itoa(int(post.ID))}, inputJSON, token)
+		assert.Equal(t, rec.Code, http.StatusOK)
+		updated := models.Post{}
+		err = json.Unmarshal([]byte(rec.Body.String()), &updated)
+		if err != nil {
+			t.Errorf("Cannot convert to json: %v", err)
+		}
+		return updated
+	}
+	renamed := rename(first, "Goodbye World")
+	assert.Equal(t, renamed.Slug, "goodbye-world")
+
+	rec = postRequest(server.GetPostBySlug, "GET", "/posts/by-slug/hello-world", map[string]string{"slug": "hello-world"}, "", "")
+	assert.Equal(t, rec.Code, http.StatusMovedPermanently)
+	assert.Equal(t, rec.Header().Get("Location"), "/posts/by-slug/goodbye-world")
+
+	// Old slugs are not handed out again, so the redirect keeps working
+	third := newPost("Hello World")
+	assert.Equal(t, third.Slug, "hello-world-3")
+
+	// Unless the post goes back to its old title
+	restored := rename(first, "Hello World!")
+	assert.Equal(t, restored.Slug, "hello-world")
+	rec = postRequest(server.GetPostBySlug, "GET", "/posts/by-slug/goodbye-world", map[string]string{"slug": "goodbye-world"}, "", "")
+	assert.Equal(t, rec.Code, http.StatusMovedPermanently)
+	assert.Equal(t, rec.Header().Get("Location"), "/posts/by-slug/hello-world")
+
+	// Slugs are made from titles as they were written, not as they are stored
+	assert.Equal(t, newPost("Don't panic").Slug, "dont-panic")
+	assert.Equal(t, newPost("Tom & Jerry").Slug, "tom-and-jerry")
+	legacy := newPost("Rock & Roll")
+	err = server.DB.Model(&models.Post{}).Where("id = ?", legacy.ID).UpdateColumn("slug", "").Error
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = models.BackfillSlugs(server.DB)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = server.DB.Model(&models.Post{}).Where("id = ?", legacy.ID).Take(&legacy).Error
+	if err != nil {
+		log.Fatal(err)
+	}
+	assert.Equal(t, legacy.Slug, "rock-and-roll")
+
+	rec = postRequest(server.GetPostBySlug, "GET", "/posts/by-slug/unknown", map[string]string{"slug": "unknown"}, "", "")
+	assert.Equal(t, rec.Code, http.StatusNotFound)
+}
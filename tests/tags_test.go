@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	assert.Equal(t, models.NormalizeTags(nil), []string(nil))
+	assert.Equal(t, models.NormalizeTags([]string{"Go Lang", "go-lang", " ", "Crème Brûlée"}), []string{"go-lang", "creme-brulee"})
+}
+
+func TestPostTags(t *testing.T) {
+
+	err := refreshUserAndPostTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	author := seedUserWithRole("author", auth.RoleAuthor)
+	admin := seedUserWithRole("admin", auth.RoleAdmin)
+	tokens := signIn(author, admin)
+
+	create := func(title, tags string) models.Post {
+		inputJSON := fmt.Sprintf(`{"title": "%s", "content": "Content", "author_id": %d, "status": "published", "tags": %s}`, title, author.ID, tags)
+		rec := postRequest(server.CreatePost, "POST", "/posts", nil, inputJSON, tokens[author.ID])
+		assert.Equal(t, rec.Code, http.StatusCreated)
+		post := models.Post{}
+		err := json.Unmarshal([]byte(rec.Body.String()), &post)
+		if err != nil {
+			t.Errorf("Cannot convert to json: %v", err)
+		}
+		return post
+	}
+	first := create("First", `["Go", "Databases", "go"]`)
+	assert.Equal(t, first.Tags, []string{"databases", "go"})
+	create("Second", `["go", "golang"]`)
+
+	// There is a cap on how many tags a post gets
+	inputJSON := fmt.Sprintf(`{"title": "Third", "content": "Content", "author_id": %d, "tags": ["a", "b", "c", "d", "e", "f"]}`, author.ID)
+	assert.Equal(t, postRequest(server.CreatePost, "POST", "/posts", nil, inputJSON, tokens[author.ID]).Code, http.StatusUnprocessableEntity)
+
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts?tag=Go", nil, "", "")), 2)
+	assert.Equal(t, countPosts(postRequest(server.GetTagPosts, "GET", "/tags/databases/posts", map[string]string{"tag": "databases"}, "", "")), 1)
+
+	tagCounts := func() []models.TagCount {
+		rec := postRequest(server.GetTags, "GET", "/tags", nil, "", "")
+		assert.Equal(t, rec.Code, http.StatusOK)
+		counts := []models.TagCount{}
+		err := json.Unmarshal([]byte(rec.Body.String()), &counts)
+		if err != nil {
+			t.Errorf("Cannot convert to json: %v", err)
+		}
+		return counts
+	}
+	assert.Equal(t, tagCounts(), []models.TagCount{{Name: "go", Posts: 2}, {Name: "databases", Posts: 1}, {Name: "golang", Posts: 1}})
+
+	// Updates without tags leave them alone, an empty list clears them
+	vars := map[string]string{"id": strconv.Itoa(int(first.ID))}
+	inputJSON = fmt.Sprintf(`{"title": "First", "content": "Changed", "author_id": %d}`, author.ID)
+	rec := postRequest(server.UpdatePost, "PUT", "/posts", vars, inputJSON, tokens[author.ID])
+	assert.Equal(t, rec.Code, http.StatusOK)
+	updated := models.Post{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &updated)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, updated.Tags, []string{"databases", "go"})
+
+	inputJSON = fmt.Sprintf(`{"title": "First", "content": "Changed", "author_id": %d, "tags": []}`, author.ID)
+	rec = postRequest(server.UpdatePost, "PUT", "/posts", vars, inputJSON, tokens[author.ID])
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts?tag=databases", nil, "", "")), 0)
+
+	// Merging folds one tag into the other
+	second := create("Fourth", `["golang", "go"]`)
+	rec = postRequest(server.MergeTag, "POST", "/tags/golang/merge", map[string]string{"tag": "golang"}, `{"into": "go"}`, tokens[admin.ID])
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts?tag=go", nil, "", "")), 2)
+	post := models.Post{}
+	_, err = post.SinglePost(server.DB, second.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, post.Tags, []string{"go"})
+
+	// Renames keep the posts and refuse names that are taken
+	rec = postRequest(server.RenameTag, "PUT", "/tags/go", map[string]string{"tag": "go"}, `{"name": "Databases"}`, tokens[admin.ID])
+	assert.Equal(t, rec.Code, http.StatusConflict)
+	rec = postRequest(server.RenameTag, "PUT", "/tags/go", map[string]string{"tag": "go"}, `{"name": "Go Lang"}`, tokens[admin.ID])
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts?tag=go-lang", nil, "", "")), 2)
+	rec = postRequest(server.RenameTag, "PUT", "/tags/missing", map[string]string{"tag": "missing"}, `{"name": "other"}`, tokens[admin.ID])
+	assert.Equal(t, rec.Code, http.StatusNotFound)
+}
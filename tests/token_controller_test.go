@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func refreshRequest(refreshToken string) *httptest.ResponseRecorder {
+	inputJSON := fmt.Sprintf(`{"refresh_token": "%s"}`, refreshToken)
+	req, err := http.NewRequest("POST", "/token/refresh", bytes.NewBufferString(inputJSON))
+	if err != nil {
+		log.Fatalf("Error Occurred: %v\n", err)
+	}
+	rec := httptest.NewRecorder()
+	handler := http.HandlerFunc(server.RefreshToken)
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRefreshToken(t *testing.T) {
+
+	err := refreshUserTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	person, err := seedUser()
+	if err != nil {
+		log.Fatalf("Error Occurred seeding user %v\n", err)
+	}
+	tokens, err := server.SignIn(person.Email, "Password")
+	if err != nil {
+		log.Fatalf("Error occurred login: %v\n", err)
+	}
+	assert.NotEqual(t, tokens.RefreshToken, "")
+
+	// A valid refresh token is rotated into a new pair
+	rec := refreshRequest(tokens.RefreshToken)
+	assert.Equal(t, rec.Code, http.StatusOK)
+
+	responseMap := make(map[string]interface{})
+	err = json.Unmarshal([]byte(rec.Body.String()), &responseMap)
+	if err != nil {
+		t.Errorf("Error occurred converting to json: %v", err)
+	}
+	rotated, _ := responseMap["refresh_token"].(string)
+	assert.NotEqual(t, rotated, "")
+	assert.NotEqual(t, rotated, tokens.RefreshToken)
+	assert.NotEqual(t, responseMap["access_token"], "")
+
+	// Replaying the old token is rejected and revokes the whole family
+	rec = refreshRequest(tokens.RefreshToken)
+	assert.Equal(t, rec.Code, http.StatusUnauthorized)
+
+	rec = refreshRequest(rotated)
+	assert.Equal(t, rec.Code, http.StatusUnauthorized)
+
+	// Unknown and missing tokens
+	rec = refreshRequest("not a refresh token")
+	assert.Equal(t, rec.Code, http.StatusUnauthorized)
+
+	rec = refreshRequest("")
+	assert.Equal(t, rec.Code, http.StatusUnprocessableEntity)
+}
@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"github.com/mmosoroohh/Go_Medium_API/api/scheduler"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+// countTrash counts the posts of a trash listing, which is not paged
+func countTrash(rec *httptest.ResponseRecorder) int {
+	var posts []models.Post
+	err := json.Unmarshal([]byte(rec.Body.String()), &posts)
+	if err != nil {
+		log.Fatalf("Cannot convert to json: %v", err)
+	}
+	return len(posts)
+}
+
+func TestTrash(t *testing.T) {
+
+	server.TrashRetention = 24 * time.Hour
+	defer func() { server.TrashRetention = 0 }()
+	auth.SetAccountStore(models.NewDBAccountStore(server.DB))
+	defer auth.SetAccountStore(nil)
+
+	err := refreshUserAndPostTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	author := seedUserWithRole("author", auth.RoleAuthor)
+	other := seedUserWithRole("other", auth.RoleAuthor)
+	admin := seedUserWithRole("admin", auth.RoleAdmin)
+	tokens := signIn(author, other, admin)
+
+	create := func(title string) string {
+		inputJSON := fmt.Sprintf(`{"title": "%s", "content": "Content", "author_id": %d, "status": "published", "tags": ["trash"]}`, title, author.ID)
+		rec := postRequest(server.CreatePost, "POST", "/posts", nil, inputJSON, tokens[author.ID])
+		assert.Equal(t, rec.Code, http.StatusCreated)
+		post := models.Post{}
+		err := json.Unmarshal([]byte(rec.Body.String()), &post)
+		if err != nil {
+			t.Errorf("Cannot convert to json: %v", err)
+		}
+		return strconv.Itoa(int(post.ID))
+	}
+	first, second := create("First"), create("Second")
+	authorID := strconv.Itoa(int(author.ID))
+
+	// Deleted posts leave every listing, but stay in the trash
+	rec := postRequest(server.DeletePost, "DELETE", "/posts", map[string]string{"id": first}, "", tokens[author.ID])
+	assert.Equal(t, rec.Code, http.StatusNoContent)
+	assert.Equal(t, postRequest(server.GetPost, "GET", "/posts", map[string]string{"id": first}, "", tokens[author.ID]).Code, http.StatusNotFound)
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts", nil, "", "")), 1)
+	assert.Equal(t, countPosts(postRequest(server.GetTagPosts, "GET", "/tags/trash/posts", map[string]string{"tag": "trash"}, "", "")), 1)
+
+	rec = postRequest(server.GetTrash, "GET", "/users/trash", map[string]string{"id": authorID}, "", tokens[author.ID])
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, countTrash(rec), 1)
+	assert.Equal(t, postRequest(server.GetTrash, "GET", "/users/trash", map[string]string{"id": authorID}, "", tokens[other.ID]).Code, http.StatusUnauthorized)
+
+	assert.Equal(t, postRequest(server.RestorePost, "POST", "/posts/restore", map[string]string{"id": first}, "", tokens[other.ID]).Code, http.StatusUnauthorized)
+	assert.Equal(t, postRequest(server.RestorePost, "POST", "/posts/restore", map[string]string{"id": second}, "", tokens[author.ID]).Code, http.StatusNotFound)
+	rec = postRequest(server.RestorePost, "POST", "/posts/restore", map[string]string{"id": first}, "", tokens[author.ID])
+	assert.Equal(t, rec.Code, http.StatusOK)
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts", nil, "", "")), 2)
+
+	// Deleting a user takes their posts along, restoring brings back only those
+	rec = postRequest(server.DeletePost, "DELETE", "/posts", map[string]string{"id": second}, "", tokens[author.ID])
+	assert.Equal(t, rec.Code, http.StatusNoContent)
+	session, err := server.SignIn(author.Email, "password")
+	if err != nil {
+		log.Fatal(err)
+	}
+	rec = postRequest(server.DeleteUser, "DELETE", "/users", map[string]string{"id": authorID}, "", tokens[author.ID])
+	assert.Equal(t, rec.Code, http.StatusNoContent)
+
+	// Every session of the account ends with it, so only an admin can restore it
+	for _, token := range []string{tokens[author.ID], session.AccessToken} {
+		assert.Equal(t, postRequest(server.GetTrash, "GET", "/users/trash", map[string]string{"id": authorID}, "", token).Code, http.StatusUnauthorized)
+	}
+	refreshJSON := fmt.Sprintf(`{"refresh_token": "%s"}`, session.RefreshToken)
+	assert.Equal(t, postRequest(server.RefreshToken, "POST", "/token/refresh", nil, refreshJSON, "").Code, http.StatusUnauthorized)
+	assert.Equal(t, postRequest(server.RestoreUser, "POST", "/users/restore", map[string]string{"id": authorID}, "", session.AccessToken).Code, http.StatusUnauthorized)
+	count := 0
+	server.DB.Model(&models.User{}).Count(&count)
+	assert.Equal(t, count, 2)
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts", nil, "", "")), 0)
+	_, err = server.SignIn(author.Email, "password")
+	assert.NotEqual(t, err, nil)
+
+	rec = postRequest(server.RestoreUser, "POST", "/users/restore", map[string]string{"id": authorID}, "", tokens[admin.ID])
+	assert.Equal(t, rec.Code, http.StatusOK)
+	tokens[author.ID] = signIn(author)[author.ID]
+	assert.Equal(t, countPosts(postRequest(server.GetPosts, "GET", "/posts", nil, "", "")), 1)
+	assert.Equal(t, countTrash(postRequest(server.GetTrash, "GET", "/users/trash", map[string]string{"id": authorID}, "", tokens[author.ID])), 1)
+
+	// Past the grace period nothing comes back, and the purge deletes it for good
+	now := time.Now().Add(48 * time.Hour)
+	server.Now = func() time.Time { return now }
+	defer func() { server.Now = nil }()
+	assert.Equal(t, postRequest(server.RestorePost, "POST", "/posts/restore", map[string]string{"id": second}, "", tokens[author.ID]).Code, http.StatusGone)
+
+	rec = postRequest(server.DeleteUser, "DELETE", "/users", map[string]string{"id": strconv.Itoa(int(other.ID))}, "", tokens[admin.ID])
+	assert.Equal(t, rec.Code, http.StatusNoContent)
+
+	purger := scheduler.New(server.DB, time.Minute)
+	purger.Now = func() time.Time { return now }
+	purger.PurgeAfter = server.TrashRetention
+	posts, users, err := purger.Purge()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, posts, 1)
+	assert.Equal(t, users, 1)
+
+	server.DB.Unscoped().Model(&models.Post{}).Count(&count)
+	assert.Equal(t, count, 1)
+	server.DB.Model(&models.PostRevision{}).Count(&count)
+	assert.Equal(t, count, 1)
+	server.DB.Unscoped().Model(&models.User{}).Count(&count)
+	assert.Equal(t, count, 2)
+
+	// Nothing is left to purge
+	posts, users, err = purger.Purge()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, posts+users, 0)
+}
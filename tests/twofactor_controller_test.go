@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/auth"
+	"github.com/pquerna/otp/totp"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func TestValidateTOTP(t *testing.T) {
+	secret, uri, err := auth.GenerateTOTPSecret("arnold.osoro@gmail.com")
+	assert.Equal(t, err, nil)
+	assert.NotEqual(t, uri, "")
+
+	now := time.Now()
+	code, err := totp.GenerateCode(secret, now)
+	assert.Equal(t, err, nil)
+
+	step, ok := auth.ValidateTOTP(secret, code, now)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, step, now.Unix()/30)
+
+	// One period of drift either way is fine, more is not
+	_, ok = auth.ValidateTOTP(secret, code, now.Add(30*time.Second))
+	assert.Equal(t, ok, true)
+	_, ok = auth.ValidateTOTP(secret, code, now.Add(5*time.Minute))
+	assert.Equal(t, ok, false)
+	_, ok = auth.ValidateTOTP(secret, "000000x", now)
+	assert.Equal(t, ok, false)
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+
+	err := refreshUserTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	person, err := seedUser()
+	if err != nil {
+		log.Fatalf("Error Occurred seeding user %v\n", err)
+	}
+	tokens, err := server.SignIn(person.Email, "Password")
+	if err != nil {
+		log.Fatalf("Error occurred login: %v\n", err)
+	}
+	tokenString := fmt.Sprintf("Bearer %v", tokens.AccessToken)
+
+	call := func(handler http.HandlerFunc, inputJSON string, token string) (int, map[string]interface{}) {
+		req, err := http.NewRequest("POST", "/", bytes.NewBufferString(inputJSON))
+		if err != nil {
+			t.Errorf("Error Occurred: %v\n", err)
+		}
+		req.Header.Set("Authorization", token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		responseMap := make(map[string]interface{})
+		_ = json.Unmarshal([]byte(rec.Body.String()), &responseMap)
+		return rec.Code, responseMap
+	}
+
+	code, response := call(server.EnrollTwoFactor, "", tokenString)
+	assert.Equal(t, code, http.StatusCreated)
+	secret := response["secret"].(string)
+
+	code, _ = call(server.ConfirmTwoFactor, `{"code": "123456"}`, tokenString)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+
+	totpCode, _ := totp.GenerateCode(secret, time.Now())
+	code, response = call(server.ConfirmTwoFactor, fmt.Sprintf(`{"code": "%s"}`, totpCode), tokenString)
+	assert.Equal(t, code, http.StatusOK)
+	recoveryCodes := response["recovery_codes"].([]interface{})
+	assert.Equal(t, len(recoveryCodes), 10)
+
+	// The password alone now only gets a mfa pending token, which is not an access token
+	tokens, err = server.SignIn(person.Email, "Password")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, tokens.MFARequired, true)
+	assert.Equal(t, tokens.AccessToken, "")
+	code, _ = call(server.EnrollTwoFactor, "", fmt.Sprintf("Bearer %v", tokens.MFAToken))
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	// The TOTP code was already used to confirm, so it can't be replayed
+	code, _ = call(server.LoginMFA, fmt.Sprintf(`{"mfa_token": "%s", "code": "%s"}`, tokens.MFAToken, totpCode), "")
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	recoveryCode := recoveryCodes[0].(string)
+	code, response = call(server.LoginMFA, fmt.Sprintf(`{"mfa_token": "%s", "code": "%s"}`, tokens.MFAToken, recoveryCode), "")
+	assert.Equal(t, code, http.StatusOK)
+	assert.NotEqual(t, response["access_token"], "")
+
+	// So is the mfa token, once it was exchanged for a token pair
+	code, _ = call(server.LoginMFA, fmt.Sprintf(`{"mfa_token": "%s", "code": "%s"}`, tokens.MFAToken, recoveryCodes[2]), "")
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	// Recovery codes are single use too
+	tokens, err = server.SignIn(person.Email, "Password")
+	assert.Equal(t, err, nil)
+	code, _ = call(server.LoginMFA, fmt.Sprintf(`{"mfa_token": "%s", "code": "%s"}`, tokens.MFAToken, recoveryCode), "")
+	assert.Equal(t, code, http.StatusUnauthorized)
+
+	// Disabling needs the password as well as a code, and wrong guesses are throttled like LoginMFA
+	code, _ = call(server.DisableTwoFactor, fmt.Sprintf(`{"password": "wrong", "code": "%s"}`, recoveryCodes[1]), tokenString)
+	assert.Equal(t, code, http.StatusUnauthorized)
+	for i := 0; i < 10 && code != http.StatusTooManyRequests; i++ {
+		code, _ = call(server.DisableTwoFactor, fmt.Sprintf(`{"password": "wrong", "code": "%s"}`, recoveryCodes[1]), tokenString)
+	}
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	server.LoginAttempts.Reset(fmt.Sprintf("mfa:%d", person.ID))
+	code, _ = call(server.DisableTwoFactor, fmt.Sprintf(`{"password": "Password", "code": "%s"}`, recoveryCodes[1]), tokenString)
+	assert.Equal(t, code, http.StatusNoContent)
+
+	tokens, err = server.SignIn(person.Email, "Password")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, tokens.MFARequired, false)
+}
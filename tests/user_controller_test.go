@@ -114,13 +114,15 @@ func TestGetUsers(t *testing.T) {
 	handler := http.HandlerFunc(server.GetUsers)
 	handler.ServeHTTP(rec, req)
 
-	var users []models.User
-	err = json.Unmarshal([]byte(rec.Body.String()), &users)
+	var page struct {
+		Data []models.User `json:"data"`
+	}
+	err = json.Unmarshal([]byte(rec.Body.String()), &page)
 	if err != nil {
 		log.Fatal("Error occurred converting to json: %v\n", err)
 	}
 	assert.Equal(t, rec.Code, http.StatusOK)
-	assert.Equal(t, len(users), 2)
+	assert.Equal(t, len(page.Data), 2)
 }
 
 func TestGetUser(t *testing.T) {
@@ -209,7 +211,7 @@ func TestModifyUser(t *testing.T) {
 	if err != nil {
 		log.Fatal("Error occurred cannot login: %v\n", err)
 	}
-	tokenString := fmt.Sprintf("Bearer %v", token)
+	tokenString := fmt.Sprintf("Bearer %v", token.AccessToken)
 
 	samples := []struct {
 		id             string
@@ -362,7 +364,7 @@ func TestRemoveUser(t *testing.T) {
 	if err != nil {
 		log.Fatalf("Error Occurred login user: %v\n", err)
 	}
-	tokenString := fmt.Sprintf("Bearer %v", token)
+	tokenString := fmt.Sprintf("Bearer %v", token.AccessToken)
 
 	userSample := []struct {
 		id           string
@@ -18,7 +18,7 @@ func TestAllUsers(t *testing.T) {
 		log.Fatal(err)
 	}
 
-	users, err := user.AllUsers(server.DB)
+	users, _, err := user.AllUsers(server.DB, models.UserFilter{}, models.Paging{})
 	if err != nil {
 		t.Errorf("Error occurred with getting users: %v\n", err)
 		return
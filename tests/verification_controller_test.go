@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmosoroohh/Go_Medium_API/api/mailer"
+	"github.com/mmosoroohh/Go_Medium_API/api/models"
+	"gopkg.in/go-playground/assert.v1"
+)
+
+func TestEmailVerification(t *testing.T) {
+
+	mail := mailer.NewLogMailer(ioutil.Discard)
+	server.Mailer = mail
+	server.RequireVerifiedEmail = true
+	defer func() { server.RequireVerifiedEmail = false }()
+
+	err := refreshUserAndPostTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	inputJSON := `{"username": "verifyme", "email": "verify.me@gmail.com", "password": "password"}`
+	req, err := http.NewRequest("POST", "/users", bytes.NewBufferString(inputJSON))
+	if err != nil {
+		t.Errorf("Error Occurred: %v\n", err)
+	}
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(server.CreateUser).ServeHTTP(rec, req)
+	assert.Equal(t, rec.Code, http.StatusCreated)
+
+	created := models.User{}
+	err = json.Unmarshal([]byte(rec.Body.String()), &created)
+	if err != nil {
+		t.Errorf("Error occurred converting to json: %v", err)
+	}
+	assert.Equal(t, created.EmailVerified(), false)
+
+	tokens, err := server.SignIn(created.Email, "password")
+	if err != nil {
+		log.Fatalf("Error occurred login: %v\n", err)
+	}
+	tokenString := fmt.Sprintf("Bearer %v", tokens.AccessToken)
+
+	createPost := func() int {
+		postJSON := fmt.Sprintf(`{"title": "Unverified", "content": "Some content", "author_id": %d}`, created.ID)
+		req, err := http.NewRequest("POST", "/posts", bytes.NewBufferString(postJSON))
+		if err != nil {
+			t.Errorf("Error Occurred: %v\n", err)
+		}
+		req.Header.Set("Authorization", tokenString)
+		rec := httptest.NewRecorder()
+		http.HandlerFunc(server.CreatePost).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Unverified users can't post
+	assert.Equal(t, createPost(), http.StatusForbidden)
+
+	// Resending straight away is rate limited
+	req, err = http.NewRequest("POST", "/users/verify/resend", nil)
+	if err != nil {
+		t.Errorf("Error Occurred: %v\n", err)
+	}
+	req.Header.Set("Authorization", tokenString)
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(server.ResendVerification).ServeHTTP(rec, req)
+	assert.Equal(t, rec.Code, http.StatusTooManyRequests)
+	assert.NotEqual(t, rec.Header().Get("Retry-After"), "")
+
+	mailedToken := func(email string) string {
+		msg, ok := mail.Last(email)
+		assert.Equal(t, ok, true)
+		return strings.Fields(msg.Body[strings.Index(msg.Body, "token=")+len("token="):])[0]
+	}
+	oldToken := mailedToken(created.Email)
+
+	// Changing the address mails a new token, rate limited like resending, and the old token stops working
+	vars := map[string]string{"id": fmt.Sprintf("%d", created.ID)}
+	updateJSON := `{"username": "verifyme", "email": "someone.else@gmail.com", "password": "password"}`
+	rec = postRequest(server.UpdateUser, "PUT", "/users", vars, updateJSON, tokens.AccessToken)
+	assert.Equal(t, rec.Code, http.StatusTooManyRequests)
+	err = server.DB.Model(&models.EmailVerification{}).Where("user_id = ?", created.ID).UpdateColumn("created_at", time.Now().Add(-time.Hour)).Error
+	if err != nil {
+		log.Fatalf("Cannot backdate verification mails: %v", err)
+	}
+	rec = postRequest(server.UpdateUser, "PUT", "/users", vars, updateJSON, tokens.AccessToken)
+	assert.Equal(t, rec.Code, http.StatusOK)
+	rec = postRequest(server.VerifyEmail, "GET", "/users/verify?token="+oldToken, nil, "", "")
+	assert.Equal(t, rec.Code, http.StatusUnprocessableEntity)
+	token := mailedToken("someone.else@gmail.com")
+
+	req, err = http.NewRequest("GET", "/users/verify?token="+token, nil)
+	if err != nil {
+		t.Errorf("Error Occurred: %v\n", err)
+	}
+	rec = httptest.NewRecorder()
+	http.HandlerFunc(server.VerifyEmail).ServeHTTP(rec, req)
+	assert.Equal(t, rec.Code, http.StatusOK)
+
+	assert.Equal(t, createPost(), http.StatusCreated)
+}